@@ -0,0 +1,147 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package autocorr computes the normalised autocorrelation function of a
+// time series and its integrated autocorrelation time. The latter
+// determines the number of effectively independent samples of correlated
+// data, e.g., from molecular dynamics or Monte Carlo simulations.
+//
+// NOTE: see A. Sokal, Monte Carlo Methods in Statistical Mechanics:
+//       Foundations and New Algorithms (1996)
+//
+package autocorr
+
+import (
+  "math"
+)
+
+
+
+// DefaultWindow is the factor c of the automatic windowing rule used
+// unless requested otherwise
+const DefaultWindow = 5.0
+
+
+
+// direct_max is the length of the longest series whose autocorrelation
+// function is computed directly instead of via FFT
+const direct_max = 256
+
+
+
+// Function returns the normalised autocorrelation function of data for
+// lags 0 to len(data) - 1 based on the biased estimate of the
+// autocovariance
+//
+//   C(t) = 1/n sum_i (x_i - mean)(x_(i+t) - mean)
+//
+// normalised by C(0). Long series are processed via FFT in O(n log n).
+// A constant series has an autocorrelation of 0 for all lags > 0.
+func Function(data []float64) []float64 {
+
+  n := len(data)
+  if n == 0 {
+    return nil
+  }
+
+  mean := 0.0
+  for _, v := range data {
+    mean += v
+  }
+  mean /= float64(n)
+
+  var acf []float64
+  if n <= direct_max {
+    acf = direct(data, mean)
+  } else {
+    acf = via_fft(data, mean)
+  }
+
+  c0 := acf[0]
+  for t := range acf {
+    if c0 == 0.0 {
+      acf[t] = 0.0
+    } else {
+      acf[t] /= c0
+    }
+  }
+  acf[0] = 1.0
+  return acf
+}
+
+
+
+// Time returns the integrated autocorrelation time
+//
+//   tau(M) = 1 + 2 sum_(t=1)^M acf(t)
+//
+// for the smallest window M with M >= c tau(M) (Sokal's automatic
+// windowing) such that n/tau is the effective number of independent
+// samples. If the window condition is never met, e.g., since the series
+// is too short, tau is summed over all lags and the returned bool is
+// false. The same holds if the noise of a short series drives tau to
+// zero or below.
+func Time(acf []float64, c float64) (float64, int, bool) {
+
+  if len(acf) == 0 {
+    return math.NaN(), 0, false
+  }
+
+  tau := 1.0
+  for m := 1; m < len(acf); m++ {
+    tau += 2.0*acf[m]
+    if tau <= 0.0 {
+      return tau, m, false
+    } else if float64(m) >= c*tau {
+      return tau, m, true
+    }
+  }
+  return tau, len(acf) - 1, false
+}
+
+
+
+// direct returns the unnormalised autocovariance of data computed
+// directly in O(n^2)
+func direct(data []float64, mean float64) []float64 {
+
+  acf := make([]float64, len(data))
+  for t := range acf {
+    sum := 0.0
+    for i := 0; i + t < len(data); i++ {
+      sum += (data[i] - mean)*(data[i+t] - mean)
+    }
+    acf[t] = sum
+  }
+  return acf
+}
+
+
+
+// via_fft returns the unnormalised autocovariance of data computed as
+// the inverse transform of the power spectrum. The series is zero padded
+// to at least twice its length to avoid wrapping around.
+func via_fft(data []float64, mean float64) []float64 {
+
+  size := 1
+  for size < 2*len(data) {
+    size <<= 1
+  }
+
+  a := make([]complex128, size)
+  for i, v := range data {
+    a[i] = complex(v - mean, 0.0)
+  }
+  fft(a, false)
+  for i, v := range a {
+    a[i] = complex(real(v)*real(v) + imag(v)*imag(v), 0.0)
+  }
+  fft(a, true)
+
+  acf := make([]float64, len(data))
+  for t := range acf {
+    acf[t] = real(a[t])/float64(size)
+  }
+  return acf
+}
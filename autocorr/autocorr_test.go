@@ -0,0 +1,125 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package autocorr computes the normalised autocorrelation function of a
+// time series and its integrated autocorrelation time.
+package autocorr
+
+import (
+  "math"
+  "math/cmplx"
+  "math/rand"
+  "testing"
+)
+
+
+// Tests for the fast Fourier transform
+func Test_Autocorr_1(t *testing.T) {
+
+  data := []complex128{1, 2, -1, 0.5, 3, 0, 0, -2}
+  a := append([]complex128{}, data...)
+  fft(a, false)
+
+  n := len(data)
+  for k := range a {
+    var expected complex128
+    for j, v := range data {
+      expected += v*cmplx.Exp(complex(0, -2.0*math.Pi*float64(j*k)/float64(n)))
+    }
+    if cmplx.Abs(a[k] - expected) > 1e-12 {
+      t.Errorf("Autocorr test 1 failed - coefficient %d is %v expected %v",
+        k, a[k], expected)
+    }
+  }
+
+  fft(a, true)
+  for k, v := range a {
+    if cmplx.Abs(v/complex(float64(n), 0) - data[k]) > 1e-12 {
+      t.Errorf("Autocorr test 2 failed - inverse transform %v", a)
+      break
+    }
+  }
+}
+
+
+// Tests for the autocorrelation function
+func Test_Autocorr_2(t *testing.T) {
+
+  result_3 := Function([]float64{1, 2, 3, 4})
+  if !float_array_equal(result_3, []float64{1.0, 0.25, -0.3, -0.45}) {
+    t.Errorf("Autocorr test 3 failed - got %v", result_3)
+  }
+
+  result_4 := Function([]float64{2, 2, 2})
+  if !float_array_equal(result_4, []float64{1.0, 0.0, 0.0}) {
+    t.Errorf("Autocorr test 4 failed - got %v", result_4)
+  }
+
+  // the FFT yields the autocovariance of the direct sum
+  r := rand.New(rand.NewSource(1))
+  data := make([]float64, 3*direct_max)
+  for i := range data {
+    data[i] = r.Float64()
+  }
+  d, f := direct(data, 0.5), via_fft(data, 0.5)
+  for i := range d {
+    if math.Abs(d[i] - f[i]) > 1e-9*math.Abs(d[0]) {
+      t.Fatalf("Autocorr test 5 failed - lag %d: %v vs %v", i, d[i], f[i])
+    }
+  }
+}
+
+
+// Tests for the integrated autocorrelation time
+func Test_Autocorr_3(t *testing.T) {
+
+  // acf(t) = 0.5^t yields tau = 1 + 2 sum_t 0.5^t -> 3
+  acf := make([]float64, 40)
+  for i := range acf {
+    acf[i] = math.Pow(0.5, float64(i))
+  }
+  tau, window, ok := Time(acf, DefaultWindow)
+  if !ok || window != 15 || math.Abs(tau - 3.0) > 1e-4 {
+    t.Errorf("Autocorr test 6 failed - got tau %v window %d (%v)", tau,
+      window, ok)
+  }
+
+  _, window, ok = Time(acf[:10], DefaultWindow)
+  if ok || window != 9 {
+    t.Errorf("Autocorr test 7 failed - window %d was accepted", window)
+  }
+
+  _, window, ok = Time([]float64{1.0, 0.0, -0.5}, DefaultWindow)
+  if ok || window != 2 {
+    t.Errorf("Autocorr test 8 failed - vanishing tau was accepted")
+  }
+
+  // an AR(1) process x_i = phi x_(i-1) + e_i has tau = (1 + phi)/(1 - phi)
+  r := rand.New(rand.NewSource(1))
+  data := make([]float64, 1 << 16)
+  for i := 1; i < len(data); i++ {
+    data[i] = 0.5*data[i-1] + r.NormFloat64()
+  }
+  tau, _, ok = Time(Function(data), DefaultWindow)
+  if !ok || math.Abs(tau - 3.0) > 0.15 {
+    t.Errorf("Autocorr test 9 failed - got tau %v", tau)
+  }
+}
+
+
+// Support Functions
+//
+// float_array_equal compares two arrays of floats for equality
+func float_array_equal(a1, a2 []float64) bool {
+  if len(a1) != len(a2) {
+    return false
+  }
+
+  for i, v := range a1 {
+    if math.Abs(a2[i] - v) > 1e-13*math.Max(1.0, math.Abs(v)) {
+      return false
+    }
+  }
+  return true
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autocorr
+
+import (
+  "math"
+)
+
+
+
+// fft computes the discrete Fourier transform of a in place via the
+// iterative radix-2 Cooley-Tukey algorithm. The length of a has to be a
+// power of two. With inverse the unnormalised inverse transform is
+// computed.
+func fft(a []complex128, inverse bool) {
+
+  n := len(a)
+
+  // bit reversal permutation
+  for i, j := 1, 0; i < n; i++ {
+    bit := n >> 1
+    for ; j&bit != 0; bit >>= 1 {
+      j ^= bit
+    }
+    j ^= bit
+    if i < j {
+      a[i], a[j] = a[j], a[i]
+    }
+  }
+
+  sign := -1.0
+  if inverse {
+    sign = 1.0
+  }
+
+  // NOTE: the twiddle factors are computed directly instead of by
+  //       repeated multiplication to avoid accumulating rounding errors
+  for size := 2; size <= n; size <<= 1 {
+    half := size >> 1
+    angle := sign*2.0*math.Pi/float64(size)
+    for k := 0; k < half; k++ {
+      w := complex(math.Cos(angle*float64(k)), math.Sin(angle*float64(k)))
+      for start := 0; start < n; start += size {
+        u := a[start+k]
+        v := a[start+k+half]*w
+        a[start+k] = u + v
+        a[start+k+half] = u - v
+      }
+    }
+  }
+}
@@ -0,0 +1,213 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package average
+
+import (
+  "fmt"
+  "math"
+  "strings"
+)
+
+
+
+// AlignMode describes how files with a differing number of rows are
+// combined
+type AlignMode int
+
+const (
+  AlignStrict AlignMode = iota   // reject files whose length differs
+  AlignTruncate                  // truncate all files to the shortest one
+  AlignPad                       // pad shorter files with NaN
+  AlignInterpolate               // interpolate onto a common grid of the
+                                 // reference column
+)
+
+
+
+// ParseAlignMode turns the name of an alignment mode (strict, truncate,
+// pad or interpolate) into an AlignMode
+func ParseAlignMode(name string) (AlignMode, error) {
+  switch strings.ToLower(name) {
+  case "strict":
+    return AlignStrict, nil
+  case "truncate":
+    return AlignTruncate, nil
+  case "pad":
+    return AlignPad, nil
+  case "interpolate":
+    return AlignInterpolate, nil
+  }
+  return AlignStrict, fmt.Errorf("unknown alignment mode %q", name)
+}
+
+
+
+// align determines the number of rows of the average once the columns of
+// a file with the given number of rows are added. Files which can not be
+// aligned are rejected.
+func (a *averager) align(name string, rows int) (int, error) {
+
+  if rows == a.rows {
+    return rows, nil
+  }
+
+  switch a.opts.Align {
+  case AlignTruncate:
+    if rows < a.rows {
+      return rows, nil
+    }
+    return a.rows, nil
+  case AlignPad:
+    if rows > a.rows {
+      return rows, nil
+    }
+    return a.rows, nil
+  }
+  return 0, fmt.Errorf("%s: mismatched column length of %d rows instead " +
+    "of %d", name, rows, a.rows)
+}
+
+
+
+// resize truncates a column to n rows or pads it with NaN
+func resize(c column, n int) column {
+  if n <= len(c) {
+    return c[:n]
+  }
+
+  for len(c) < n {
+    c = append(c, math.NaN())
+  }
+  return c
+}
+
+
+
+// resize truncates all accumulated rows to n rows or pads them with NaN
+func (a *accumulator) resize(n int) {
+  a.sum = resize(a.sum, n)
+  a.weight = resize(a.weight, n)
+  a.weight2 = resize(a.weight2, n)
+  a.m2 = resize(a.m2, n)
+  a.min = resize(a.min, n)
+  a.max = resize(a.max, n)
+}
+
+
+
+// interpolate adds all files held back for interpolation. The common grid
+// consists of the reference values of the first file within the range
+// covered by all files. Files whose reference column is not strictly
+// increasing are rejected.
+func (a *averager) interpolate() []error {
+
+  var errors []error
+  var valid []fileResult
+  lo, hi := math.Inf(-1), math.Inf(1)
+  for _, r := range a.pending {
+    x := r.cols[0]
+    if !increasing(x) {
+      errors = append(errors, fmt.Errorf("%s: reference column %s is not " +
+        "strictly increasing", r.name, a.opts.Reference))
+      continue
+    }
+
+    if len(x) == 0 {
+      lo, hi = math.Inf(1), math.Inf(-1)
+    } else {
+      lo, hi = math.Max(lo, x[0]), math.Min(hi, x[len(x)-1])
+    }
+    valid = append(valid, r)
+  }
+  a.pending = nil
+
+  if len(valid) == 0 {
+    return errors
+  }
+
+  grid := column{}
+  for _, v := range valid[0].cols[0] {
+    if v >= lo && v <= hi {
+      grid = append(grid, v)
+    }
+  }
+
+  for _, r := range valid {
+    cols := []column{grid}
+    missing := []mask{nil}
+    for i, c := range r.cols[1:] {
+      cols = append(cols, interpolate(r.cols[0], c, grid))
+      missing = append(missing, interpolate_mask(r.cols[0], r.missing[i+1],
+        grid))
+    }
+    if err := a.add(fileResult{r.name, cols, missing}); err != nil {
+      errors = append(errors, err)
+    }
+  }
+  return errors
+}
+
+
+
+// interpolate_mask returns the mask of the grid points whose
+// interpolation involves a missing value
+func interpolate_mask(x column, m mask, grid column) mask {
+
+  if m == nil {
+    return nil
+  }
+
+  flags := make(column, len(m))
+  for i, missing := range m {
+    if missing {
+      flags[i] = 1.0
+    }
+  }
+
+  output := make(mask, len(grid))
+  for i, f := range interpolate(x, flags, grid) {
+    output[i] = f > 0.0
+  }
+  return output
+}
+
+
+
+// increasing returns true if the values of a column are strictly
+// increasing
+func increasing(c column) bool {
+  for i := 1; i < len(c); i++ {
+    if !(c[i] > c[i-1]) {
+      return false
+    }
+  }
+  return true
+}
+
+
+
+// interpolate evaluates the piecewise linear function through the points
+// (x, y) at the increasing positions grid which lie within the range of x
+func interpolate(x, y, grid column) column {
+
+  output := make(column, len(grid))
+  j := 0
+  for i, g := range grid {
+    for j < len(x)-2 && x[j+1] <= g {
+      j++
+    }
+
+    switch {
+    case g == x[j]:
+      output[i] = y[j]
+    case g == x[j+1]:
+      output[i] = y[j+1]
+    default:
+      t := (g - x[j])/(x[j+1] - x[j])
+      output[i] = y[j] + t*(y[j+1] - y[j])
+    }
+  }
+  return output
+}
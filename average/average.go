@@ -7,14 +7,17 @@
 //
 // NOTE: File processing is done via goroutines using a nummber of
 //       workers
+//
+// Streams which are not files can be averaged via FromReaders and a
+// single stream parsed via ReadColumns.
 package average
 
 import (
-  "bufio"
-  "log"
-  "os"
-  "strconv"
-  "strings"
+  "fmt"
+  "io"
+  "math"
+  "github.com/haskelladdict/lizard/parser"
+  "github.com/haskelladdict/lizard/pool"
 )
 
 
@@ -24,169 +27,600 @@ type column []float64
 
 
 
-// job described the parsing work to be done by a single worker
-type job struct {
-  fileName string
-  colID int
-  results chan<- column
+// mask flags the rows of a column whose value is missing and thus left
+// out of the average. A nil mask has no missing rows.
+type mask []bool
+
+
+
+// Options describes the columns to average and how to parse the input.
+// Besides the mean the spread of each row across files can be requested
+// which adds extra result columns following the mean of each column.
+type Options struct {
+  Columns []parser.Column
+  Spread bool         // add the variance, standard deviation and standard
+                      // error of the mean of each row
+  Envelope bool       // add the minimum and maximum of each row
+  Reference *parser.Column  // column carried through unchanged, e.g.,
+                            // time or x values, nil for none
+  Align AlignMode     // handling of files with differing number of rows
+  Weight *parser.Column  // column of non-negative weights of each row of
+                         // a file, nil for equal weights
+  Parse parser.Config
+}
+
+
+
+// accumulator keeps track of the row wise statistic of a single column
+// across files. The mean is computed from the (weighted) sum of the rows
+// so it does not depend on the order in which the files are processed, m2
+// is the weighted sum of the squared deviations from the mean updated via
+// Welford's method. Without weights each row has weight 1.
+type accumulator struct {
+  count int
+  sum, weight, weight2, m2, min, max column
+}
+
+
+
+// averager combines the columns of all files in input order so the
+// result does not depend on the order in which the workers finish. The
+// reference column of the first file in input order is kept and compared
+// with the one of all other files. With AlignInterpolate all files are
+// held back until the common grid is known.
+type averager struct {
+  opts Options
+  rows int
+  reference column
+  accs []accumulator
+  pending []fileResult
 }
 
 
 
-// done is used to signal that a worker has finished his assigned
-// jobs. The done struct also conveys how many files were successfully
-// processed so the analysis routine can do a proper average
-type doneStatus struct {
-  files_processed int
+// fileResult contains the columns parsed from a single file and the
+// masks of their missing values. If a reference column was requested it
+// is the first column.
+type fileResult struct {
+  name string
+  cols []column
+  missing []mask
 }
 
 
 
-// add_jobs adds all parsing jobs to the work queue (one per data file)
-func add_jobs(fileNames []string, colID int, jobs chan<- job,
-  result chan<- column) {
-  for _, name := range fileNames {
-    jobs <- job{name, colID, result}
+// ReadColumns parses the requested columns of a plain text column
+// oriented data stream preceded by the reference column, if any. The
+// name of the input is used for error reporting. Parse failures are
+// returned as *parser.ParseError. With MissingSkip rows with missing
+// values are skipped for all columns.
+func ReadColumns(r io.Reader, name string,
+  opts Options) ([][]float64, error) {
+
+  cols, missing, err := read_columns(r, name, opts)
+  if err != nil {
+    return nil, err
   }
-  close(jobs)
+  return to_floats(drop_missing(cols, missing)), nil
 }
 
 
 
-// start_jobs starts jobs still in the queue one by one. Each
-// worker processes a separate start_jobs goroutine
-func start_jobs(done chan<- doneStatus, jobs <-chan job) {
-  num_processed := 0
-  for job := range jobs {
-    success := job.run()
-    if success {
-      num_processed++
+// read_columns parses the requested columns of a plain text column
+// oriented data file and returns them together with the mask of their
+// missing values.
+//
+// NOTE: With MissingSkip rows with missing values are kept so the rows
+//       of all files stay aligned. The missing values are set to NaN and
+//       flagged in the mask of their column to leave the file out of
+//       the average of the row.
+func read_columns(file io.Reader, name string,
+  opts Options) ([]column, []mask, error) {
+
+  cols, conf := opts.columns(), opts.Parse
+  scanner := parser.NewScanner(file, name, conf)
+  output := make([]column, len(cols))
+  missing := make([]mask, len(cols))
+  var colIDs []int
+  for scanner.Scan() {
+    if colIDs == nil {
+      var err error
+      if colIDs, err = scanner.Columns(cols); err != nil {
+        return nil, nil, err
+      }
+    }
+
+    for i, colID := range colIDs {
+      val, is_missing, err := scanner.Value(colID)
+      if err != nil {
+        return nil, nil, err
+      }
+
+      skip := is_missing && conf.Missing == parser.MissingSkip
+      if skip && missing[i] == nil {
+        missing[i] = make(mask, len(output[i]))
+      }
+      if missing[i] != nil {
+        missing[i] = append(missing[i], skip)
+      }
+      if skip {
+        val = math.NaN()
+      }
+      output[i] = append(output[i], val)
     }
   }
-  done <- doneStatus{num_processed}
+  if err := scanner.Err(); err != nil {
+    return nil, nil, err
+  }
+
+  return output, missing, nil
 }
 
 
 
-// run_jobs does the actual processing of a single job descriptonr,
-// i.e., it parses the file and the pushes the content into
-// the results channel.
-// NOTE: the column parsing code below can panic in case the user supplies
-//       an invalid column ID or the data file itself is damaged. In this
-//       case we recover and ignore the whole file.
-func (j job) run() bool {
+// drop_missing removes all rows with a missing value in any column
+func drop_missing(cols []column, missing []mask) []column {
 
-  // ignore data file processed by this job in case of a panic
-  defer func() bool {
-    if r := recover(); r != nil {
-      log.Printf("Warning: Failed to parse file %s. Ignoring file. " +
-        "Did you pick the correct column??", j.fileName)
+  output := make([]column, len(cols))
+  for i, col := range cols {
+    for r, v := range col {
+      skip := false
+      for _, m := range missing {
+        skip = skip || m.at(r)
+      }
+      if !skip {
+        output[i] = append(output[i], v)
+      }
     }
-    return false
-  }()
+  }
+  return output
+}
 
-  // main processing
-  file, err := os.Open(j.fileName)
-  if err != nil {
-    log.Printf("Warning: Failed to open file %s. Ignoring file.\n",
-      j.fileName)
-    return false
+
+
+// at returns true if row i is missing
+func (m mask) at(i int) bool {
+  return i < len(m) && m[i]
+}
+
+
+
+// either returns the mask of rows missing in m1 or m2
+func either(m1, m2 mask) mask {
+  if m1 == nil {
+    return m2
+  } else if m2 == nil {
+    return m1
   }
-  defer file.Close()
 
-  scanner := bufio.NewScanner(file)
-  output := make([]float64,0)
-  for scanner.Scan() {
-    col, err := strconv.ParseFloat(strings.Fields(scanner.Text())[j.colID], 64)
-    if err != nil {
-      log.Printf("Warning: Failed to parse file %s. Ignoring file.\n",
-        j.fileName)
-      return false
+  output := make(mask, len(m1))
+  for i := range output {
+    output[i] = m1[i] || m2.at(i)
+  }
+  return output
+}
+
+
+
+// Labels returns a label for each column of the result of Average. The
+// averaged columns are labeled by their column id or name, the columns
+// describing their spread and envelope carry a suffix (_var, _std, _sem,
+// _min and _max).
+func (o Options) Labels() []string {
+
+  var labels []string
+  if o.Reference != nil {
+    labels = append(labels, o.Reference.String())
+  }
+
+  for _, c := range o.Columns {
+    label := c.String()
+    labels = append(labels, label)
+    if o.Spread {
+      labels = append(labels, label + "_var", label + "_std", label + "_sem")
     }
+    if o.Envelope {
+      labels = append(labels, label + "_min", label + "_max")
+    }
+  }
+  return labels
+}
+
+
+
+// validate checks that a reference column is given for interpolation
+func (o Options) validate() error {
+  if o.Align == AlignInterpolate && o.Reference == nil {
+    return fmt.Errorf("interpolation requires a reference column")
+  }
+  return nil
+}
+
+
+
+// columns returns the columns to be read from each file, i.e., the
+// reference column followed by the columns to average and the weight
+// column
+func (o Options) columns() []parser.Column {
+  var cols []parser.Column
+  if o.Reference != nil {
+    cols = append(cols, *o.Reference)
+  }
+  cols = append(cols, o.Columns...)
+  if o.Weight != nil {
+    cols = append(cols, *o.Weight)
+  }
+  return cols
+}
+
+
 
-    output = append(output, col)
+// push adds the columns of the next file in input order to the
+// corresponding accumulators after aligning them according to
+// opts.Align. Files whose reference column differs from the one of the
+// first file or has missing values are rejected.
+func (a *averager) push(result fileResult) error {
+
+  if err := a.check_missing_reference(result); err != nil {
+    return err
+  } else if a.opts.Align == AlignInterpolate {
+    a.pending = append(a.pending, result)
+    return nil
   }
+  return a.add(result)
+}
 
-  j.results <- output
-  return true
+
+
+// check_missing_reference rejects a file with missing values in its
+// reference column since its rows can not be matched with other files
+func (a *averager) check_missing_reference(result fileResult) error {
+
+  if a.opts.Reference == nil || len(result.missing) == 0 {
+    return nil
+  }
+  for i, m := range result.missing[0] {
+    if m {
+      return fmt.Errorf("%s: missing value in reference column %s in " +
+        "row %d", result.name, a.opts.Reference, i)
+    }
+  }
+  return nil
 }
 
 
 
-// process_column adds a column to the provided accumulator column
-func process_column(result column, acc column) column {
+// add aligns the columns of a file and adds them to the accumulators.
+// Rows with a missing weight are left out of the average of all columns.
+func (a *averager) add(result fileResult) error {
 
-  if len(acc) != 0 {
+  cols, missing := result.cols, result.missing
+  if missing == nil {
+    missing = make([]mask, len(cols))
+  }
+  rows := 0
+  if len(cols) != 0 {
+    rows = len(cols[0])
+  }
 
-    if len(result) != len(acc) {
-      log.Panic("Mismatched column length in data files. Bailing out...")
+  var ref, weights column
+  var no_weight mask
+  if a.opts.Reference != nil {
+    ref, cols, missing = cols[0], cols[1:], missing[1:]
+  }
+  if a.opts.Weight != nil {
+    weights, cols = cols[len(cols)-1], cols[:len(cols)-1]
+    no_weight, missing = missing[len(missing)-1], missing[:len(missing)-1]
+    for i, w := range weights {
+      if no_weight.at(i) {
+        continue
+      } else if !(w >= 0.0) {
+        return fmt.Errorf("%s: invalid weight %v in row %d", result.name, w,
+          i)
+      }
+    }
+  }
+
+  if a.accs == nil {
+    a.rows = rows
+    a.reference = ref
+    a.accs = make([]accumulator, len(cols))
+  } else {
+    n, err := a.align(result.name, rows)
+    if err != nil {
+      return err
     }
 
-    for i, v := range result {
-      acc[i] += v
+    if a.opts.Reference != nil {
+      if err := a.check_reference(result.name, ref, n); err != nil {
+        return err
+      }
+    }
+    for i := range a.accs {
+      a.accs[i].resize(n)
     }
+    a.rows = n
+  }
+
+  if weights != nil {
+    weights = resize(weights, a.rows)
+  }
+  for i := range cols {
+    a.accs[i].push(resize(cols[i], a.rows), weights,
+      either(missing[i], no_weight))
+  }
+  return nil
+}
+
+
+
+// check_reference compares the rows of a reference column present in
+// both the new file and the previous ones, i.e., those preceding it in
+// input order, and adjusts the stored reference column to n rows
+func (a *averager) check_reference(name string, ref column, n int) error {
+
+  common := len(ref)
+  if a.rows < common {
+    common = a.rows
+  }
+  if i := mismatch(ref[:common], a.reference[:common]); i != -1 {
+    return fmt.Errorf("%s: reference column %s differs from other " +
+      "files in row %d", name, a.opts.Reference, i)
+  }
+
+  if n > a.rows {
+    a.reference = append(a.reference[:a.rows], ref[a.rows:n]...)
   } else {
-    acc = result
+    a.reference = a.reference[:n]
+  }
+  return nil
+}
+
+
+
+// finish adds all files held back for interpolation
+func (a *averager) finish() []error {
+  if a.opts.Align != AlignInterpolate {
+    return nil
   }
+  return a.interpolate()
+}
+
 
-  return acc
+
+// mismatch returns the index of the first row in which two columns differ
+// or -1 if they are identical
+func mismatch(c1, c2 column) int {
+  for i := range c1 {
+    if i >= len(c2) || c1[i] != c2[i] {
+      return i
+    }
+  }
+  if len(c2) > len(c1) {
+    return len(c1)
+  }
+  return -1
 }
 
 
 
-// wait_and_process_results starts with data processing (averaging) while 
-// waiting for all workers to finish 
-func wait_and_process_results(results <-chan column, done <-chan doneStatus,
-  num_workers int) column {
+// push adds a column to the accumulator. The rows are weighted by the
+// corresponding entries of weights unless it is nil, rows flagged in
+// missing are left out.
+//
+// NOTE: The minimum and maximum of a row which was missing in all
+//       previous files are NaN with vanishing weight and set by the
+//       first value present.
+func (a *accumulator) push(col, weights column, missing mask) {
+
+  if a.count == 0 {
+    a.count = 1
+    a.sum = make(column, len(col))
+    a.weight = make(column, len(col))
+    a.weight2 = make(column, len(col))
+    a.m2 = make(column, len(col))
+    a.min = append(column(nil), col...)
+    a.max = append(column(nil), col...)
+    for i, v := range col {
+      if missing.at(i) {
+        a.min[i], a.max[i] = math.NaN(), math.NaN()
+        continue
+      }
+      w := row_weight(weights, i)
+      a.sum[i], a.weight[i], a.weight2[i] = w*v, w, w*w
+    }
+    return
+  }
 
-  var output column
-  num_cols := 0
+  a.count++
+  for i, v := range col {
+    if missing.at(i) {
+      continue
+    } else if math.IsNaN(a.min[i]) && a.weight[i] == 0.0 {
+      a.min[i], a.max[i] = v, v
+    }
 
-  for w := 0; w < num_workers; {
-    select {  // Blocking
-    case result := <-results:
-      output = process_column(result, output)
-    case d := <-done:
-      num_cols += d.files_processed
-      num_workers--
+    w := row_weight(weights, i)
+    delta := 0.0
+    if a.weight[i] != 0.0 {
+      delta = v - a.sum[i]/a.weight[i]
     }
+    a.sum[i] += w*v
+    a.weight[i] += w
+    a.weight2[i] += w*w
+    a.m2[i] += w*delta*(v - a.sum[i]/a.weight[i])
+    a.min[i] = math.Min(a.min[i], v)
+    a.max[i] = math.Max(a.max[i], v)
   }
+}
+
+
+
+// row_weight returns the weight of row i which is 1 without weights
+func row_weight(weights column, i int) float64 {
+  if weights == nil {
+    return 1.0
+  }
+  return weights[i]
+}
+
 
-DONE:
 
-  // process any remaining results
-  for {
-    select {
-    case result := <-results:
-      output = process_column(result, output)
-    default:
-      break DONE
+// columns returns the mean of each row followed by the spread and
+// envelope columns if requested. For weighted rows the variance is the
+// unbiased estimate for reliability weights and the standard error of the
+// mean is based on the effective number of files (sum w)^2/sum w^2.
+func (a *accumulator) columns(opts Options) []column {
+
+  mean := make(column, len(a.sum))
+  for i, v := range a.sum {
+    mean[i] = v / a.weight[i]
+  }
+  output := []column{mean}
+
+  if opts.Spread {
+    variance := make(column, len(a.m2))
+    std := make(column, len(a.m2))
+    sem := make(column, len(a.m2))
+    for i, v := range a.m2 {
+      w, w2 := a.weight[i], a.weight2[i]
+      variance[i] = v/(w - w2/w)
+      std[i] = math.Sqrt(variance[i])
+      sem[i] = std[i]/math.Sqrt(w*w/w2)
     }
+    output = append(output, variance, std, sem)
+  }
+
+  if opts.Envelope {
+    output = append(output, a.min, a.max)
+  }
+  return output
+}
+
+
+
+// columns returns the reference column, if any, followed by the averaged
+// columns and, if requested, their spread and envelope
+func (a *averager) columns() []column {
+
+  if a.accs == nil {
+    return nil
+  }
+
+  var output []column
+  if a.opts.Reference != nil {
+    output = append(output, a.reference)
+  }
+  for i := range a.accs {
+    output = append(output, a.accs[i].columns(a.opts)...)
   }
+  return output
+}
+
+
 
-  num_cols_f := float64(num_cols)
-  for i, v := range output {
-    output[i] = v / num_cols_f
+// to_floats converts a list of columns into a list of float slices
+func to_floats(cols []column) [][]float64 {
+  if cols == nil {
+    return nil
   }
 
+  output := make([][]float64, len(cols))
+  for i, col := range cols {
+    output[i] = col
+  }
   return output
 }
 
 
 
 // do_average is the main entry point for doing the averaging spawning
-// all involved worker goroutines
-func Average(fileNames []string, colID int, numWorkers int) []float64 {
-  jobs := make(chan job, numWorkers)
-  result := make(chan column, len(fileNames))
-  done := make(chan doneStatus, numWorkers)
+// all involved worker goroutines. Comment, blank and header lines as well
+// as missing values are handled according to opts.Parse and columns
+// selected by name are looked up in the last header line. The result
+// contains the reference column (with opts.Reference) followed by the
+// averaged rows of each requested column followed by the row wise
+// variance, standard deviation and standard error of the mean (with
+// opts.Spread) and the row wise minimum and maximum (with opts.Envelope)
+// across files.
+//
+// NOTE: Files with a differing number of rows are rejected, truncated,
+//       padded or interpolated onto the reference column according to
+//       opts.Align.
+//
+// NOTE: Files are combined in the order of fileNames independent of the
+//       order in which the workers finish. Files which can not be opened
+//       or parsed or whose number of rows (with AlignStrict) or reference
+//       column differs from the one of the first file are excluded from
+//       the average. Their errors are returned as parser.Errors alongside the
+//       average over all other files.
+func Average(fileNames []string, opts Options,
+  numWorkers int) ([][]float64, error) {
+
+  if err := opts.validate(); err != nil {
+    return nil, err
+  }
+
+  avg := averager{opts: opts}
+  var errors parser.Errors
+  pool.Stream(fileNames, numWorkers,
+    pool.Reading(func(r io.Reader, name string) (fileResult, error) {
+      cols, missing, err := read_columns(r, name, opts)
+      return fileResult{name, cols, missing}, err
+    }),
+    func(result fileResult, err error) {
+      if err == nil {
+        err = avg.push(result)
+      }
+      if err != nil {
+        errors = append(errors, err)
+      }
+    })
+
+  errors = append(errors, avg.finish()...)
+  output := to_floats(avg.columns())
+  if len(errors) != 0 {
+    return output, errors
+  }
+  return output, nil
+}
+
+
 
-  go add_jobs(fileNames, colID, jobs, result)
-  for i := 0; i < numWorkers; i++ {
-    go start_jobs(done, jobs)
+// FromReaders averages the requested columns across a list of input
+// streams. Names are used for error reporting and may be nil. Inputs
+// which fail to parse are excluded from the average in the same way as
+// for Average.
+func FromReaders(readers []io.Reader, names []string,
+  opts Options) ([][]float64, error) {
+
+  if err := opts.validate(); err != nil {
+    return nil, err
   }
 
-  return wait_and_process_results(result, done, numWorkers)
+  avg := averager{opts: opts}
+  var errors parser.Errors
+  for i, r := range readers {
+    name := ""
+    if names != nil {
+      name = names[i]
+    }
+
+    cols, missing, err := read_columns(r, name, opts)
+    if err == nil {
+      err = avg.push(fileResult{name, cols, missing})
+    }
+    if err != nil {
+      errors = append(errors, err)
+    }
+  }
+
+  errors = append(errors, avg.finish()...)
+  output := avg.columns()
+  if len(errors) != 0 {
+    return to_floats(output), errors
+  }
+  return to_floats(output), nil
 }
 
 
@@ -10,8 +10,11 @@
 package average
 
 import (
+  "io"
   "math"
+  "strings"
   "testing"
+  "github.com/haskelladdict/lizard/parser"
 )
 
 
@@ -19,34 +22,335 @@ func Test_Average_1(t *testing.T) {
 
 
   data_files_1 := []string{"test_files/test_data_1.txt"}
-  result_1 := Average(data_files_1, 0, 4)
+  result_1, _ := Average(data_files_1,
+    Options{Columns: []parser.Column{{Index: 0}},
+      Parse: parser.DefaultConfig()}, 4)
   expected_1 := []float64{1.0, 1.0, 1.0, 1.0}
-  if !float_array_equal(result_1, expected_1) {
+  if !float_columns_equal(result_1, [][]float64{expected_1}) {
     t.Error("Parse test 1: Failed to parse input correctly")
   }
 
 
   data_files_2 := []string{"test_files/test_data_1.txt",
     "test_files/test_data_2.txt"}
-  result_2 := Average(data_files_2, 0, 4)
+  result_2, _ := Average(data_files_2,
+    Options{Columns: []parser.Column{{Index: 0}},
+      Parse: parser.DefaultConfig()}, 4)
   expected_2 := []float64{1.5, 1.5, 1.5, 1.5}
-  if !float_array_equal(result_2, expected_2) {
+  if !float_columns_equal(result_2, [][]float64{expected_2}) {
     t.Error("Parse test 2: Failed to parse input correctly")
   }
 
 
   data_files_3 := []string{"test_files/test_data_3.txt",
     "test_files/test_data_4.txt", "test_files/test_data_5.txt"}
-  result_3 := Average(data_files_3, 1, 4)
+  result_3, _ := Average(data_files_3,
+    Options{Columns: []parser.Column{{Index: 1}},
+      Parse: parser.DefaultConfig()}, 4)
   expected_3 := []float64{23805.333333333333, 19121.333333333333,
     24376.0000, 12504.0000, 14620.3333333333333, 24463.6666666666666,
     24673.333333333333, 15413.0000, 10786.666666666666, 18102.6666666666666}
-  if !float_array_equal(result_3, expected_3) {
+  if !float_columns_equal(result_3, [][]float64{expected_3}) {
     t.Error("Parse test 3: Failed to parse input correctly")
   }
 }
 
 
+// Tests for comment, blank and header line handling
+func Test_Average_2(t *testing.T) {
+
+  data_files_4 := []string{"test_files/test_data_7.txt",
+    "test_files/test_data_6.txt"}
+  conf := parser.DefaultConfig()
+  conf.HeaderLines = 1
+  result_4, _ := Average(data_files_4,
+    Options{Columns: []parser.Column{{Index: 1}}, Parse: conf}, 4)
+  expected_4 := []float64{2.0, 2.0, 2.0, 2.0}
+  if !float_columns_equal(result_4, [][]float64{expected_4}) {
+    t.Error("Parse test 4: Failed to parse input correctly")
+  }
+
+  result_5, _ := Average(data_files_4,
+    Options{Columns: []parser.Column{{Name: "value"}}, Parse: conf}, 4)
+  if !float_columns_equal(result_5, [][]float64{expected_4}) {
+    t.Error("Parse test 5: Failed to select column by name")
+  }
+}
+
+
+// Tests for averaging several columns at once
+func Test_Average_3(t *testing.T) {
+
+  data_files_6 := []string{"test_files/test_data_3.txt",
+    "test_files/test_data_4.txt", "test_files/test_data_5.txt"}
+  cols, _ := parser.ParseColumns("0-1")
+  result_6, _ := Average(data_files_6,
+    Options{Columns: cols, Parse: parser.DefaultConfig()}, 4)
+  expected_6 := [][]float64{
+    {1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+    {23805.333333333333, 19121.333333333333, 24376.0000, 12504.0000,
+      14620.3333333333333, 24463.6666666666666, 24673.333333333333,
+      15413.0000, 10786.666666666666, 18102.6666666666666},
+  }
+  if !float_columns_equal(result_6, expected_6) {
+    t.Error("Parse test 6: Failed to average multiple columns")
+  }
+}
+
+
+// Tests for averaging in-memory streams
+func Test_Average_4(t *testing.T) {
+
+  inputs := []io.Reader{strings.NewReader("1 2\n2 4\n"),
+    strings.NewReader("3 6\n4 8\n")}
+  opts := Options{Columns: []parser.Column{{Index: 0}, {Index: 1}},
+    Parse: parser.DefaultConfig()}
+  result_7, err := FromReaders(inputs, nil, opts)
+  expected_7 := [][]float64{{2.0, 3.0}, {4.0, 6.0}}
+  if err != nil || !float_columns_equal(result_7, expected_7) {
+    t.Error("Parse test 7: Failed to average streams")
+  }
+
+  result_8, err := ReadColumns(strings.NewReader("1 x\n"), "buffer", opts)
+  if result_8 != nil || err == nil {
+    t.Error("Parse test 8: Failed to detect invalid input")
+  }
+}
+
+
+// Tests for the spread and envelope across files
+func Test_Average_5(t *testing.T) {
+
+  inputs := []io.Reader{strings.NewReader("1\n2\n"),
+    strings.NewReader("3\n4\n"), strings.NewReader("5\n9\n")}
+  opts := Options{Columns: []parser.Column{{Index: 0}}, Spread: true,
+    Envelope: true, Parse: parser.DefaultConfig()}
+  result_9, err := FromReaders(inputs, nil, opts)
+  expected_9 := [][]float64{{3.0, 5.0}, {4.0, 13.0}, {2.0, math.Sqrt(13)},
+    {2.0/math.Sqrt(3), math.Sqrt(13)/math.Sqrt(3)}, {1.0, 2.0},
+    {5.0, 9.0}}
+  if err != nil || !float_columns_equal(result_9, expected_9) {
+    t.Errorf("Parse test 9: Failed to compute spread - got %v", result_9)
+  }
+
+  labels := opts.Labels()
+  expected_labels := []string{"0", "0_var", "0_std", "0_sem", "0_min",
+    "0_max"}
+  if strings.Join(labels, " ") != strings.Join(expected_labels, " ") {
+    t.Errorf("Parse test 9: Wrong column labels %v", labels)
+  }
+}
+
+
+// Tests for carrying through a reference column
+func Test_Average_6(t *testing.T) {
+
+  data_files_10 := []string{"test_files/test_data_3.txt",
+    "test_files/test_data_4.txt", "test_files/test_data_5.txt"}
+  opts := Options{Columns: []parser.Column{{Index: 1}},
+    Reference: &parser.Column{Index: 0}, Parse: parser.DefaultConfig()}
+  result_10, err := Average(data_files_10, opts, 4)
+  expected_10 := [][]float64{
+    {1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+    {23805.333333333333, 19121.333333333333, 24376.0000, 12504.0000,
+      14620.3333333333333, 24463.6666666666666, 24673.333333333333,
+      15413.0000, 10786.666666666666, 18102.6666666666666},
+  }
+  if err != nil || !float_columns_equal(result_10, expected_10) {
+    t.Error("Parse test 10: Failed to carry through reference column")
+  }
+
+  inputs := []io.Reader{strings.NewReader("1 2\n2 4\n"),
+    strings.NewReader("1 6\n3 8\n"), strings.NewReader("1 4\n2 6\n")}
+  result_11, err := FromReaders(inputs, []string{"a", "b", "c"}, opts)
+  expected_11 := [][]float64{{1.0, 2.0}, {3.0, 5.0}}
+  if !float_columns_equal(result_11, expected_11) {
+    t.Errorf("Parse test 11: Failed to reject mismatched reference - got %v",
+      result_11)
+  }
+  if errs, ok := err.(parser.Errors); !ok || len(errs) != 1 ||
+     !strings.HasPrefix(errs[0].Error(), "b:") {
+    t.Errorf("Parse test 11: Expected error for input b - got %v", err)
+  }
+}
+
+
+// Tests for files with differing number of rows
+func Test_Average_7(t *testing.T) {
+
+  inputs := func() []io.Reader {
+    return []io.Reader{strings.NewReader("1 2\n2 4\n3 6\n"),
+      strings.NewReader("1 4\n2 6\n")}
+  }
+  opts := Options{Columns: []parser.Column{{Index: 1}},
+    Reference: &parser.Column{Index: 0}, Parse: parser.DefaultConfig()}
+
+  result_12, err := FromReaders(inputs(), []string{"a", "b"}, opts)
+  if err == nil || !float_columns_equal(result_12,
+    [][]float64{{1.0, 2.0, 3.0}, {2.0, 4.0, 6.0}}) {
+    t.Errorf("Parse test 12: Failed to reject file of different length")
+  }
+
+  opts.Align = AlignTruncate
+  result_13, err := FromReaders(inputs(), nil, opts)
+  expected_13 := [][]float64{{1.0, 2.0}, {3.0, 5.0}}
+  if err != nil || !float_columns_equal(result_13, expected_13) {
+    t.Errorf("Parse test 13: Failed to truncate - got %v", result_13)
+  }
+
+  opts.Align = AlignPad
+  result_14, err := FromReaders(inputs(), nil, opts)
+  if err != nil || len(result_14) != 2 ||
+     !float_array_equal(result_14[0], []float64{1.0, 2.0, 3.0}) ||
+     !float_array_equal(result_14[1][:2], []float64{3.0, 5.0}) ||
+     !math.IsNaN(result_14[1][2]) {
+    t.Errorf("Parse test 14: Failed to pad - got %v", result_14)
+  }
+
+  opts.Align = AlignInterpolate
+  shifted := []io.Reader{strings.NewReader("0 0\n1 2\n2 4\n3 6\n"),
+    strings.NewReader("0.5 1\n2.5 3\n4.5 5\n")}
+  result_15, err := FromReaders(shifted, nil, opts)
+  expected_15 := [][]float64{{1.0, 2.0, 3.0}, {1.75, 3.25, 4.75}}
+  if err != nil || !float_columns_equal(result_15, expected_15) {
+    t.Errorf("Parse test 15: Failed to interpolate - got %v", result_15)
+  }
+
+  opts.Reference = nil
+  if _, err := FromReaders(shifted, nil, opts); err == nil {
+    t.Error("Parse test 15: Interpolation without reference column")
+  }
+}
+
+
+// Tests for weighting the contribution of each file
+func Test_Average_8(t *testing.T) {
+
+  inputs := []io.Reader{strings.NewReader("1 1\n2 1\n"),
+    strings.NewReader("3 3\n4 1\n"), strings.NewReader("5 0\n6 0\n")}
+  opts := Options{Columns: []parser.Column{{Index: 0}}, Spread: true,
+    Weight: &parser.Column{Index: 1}, Parse: parser.DefaultConfig()}
+  result_16, err := FromReaders(inputs, nil, opts)
+  expected_16 := [][]float64{{2.5, 3.0}, {2.0, 2.0}, {math.Sqrt(2.0),
+    math.Sqrt(2.0)}, {math.Sqrt(2.0)/math.Sqrt(1.6), 1.0}}
+  if err != nil || !float_columns_equal(result_16, expected_16) {
+    t.Errorf("Parse test 16: Failed to weight files - got %v", result_16)
+  }
+
+  inputs = []io.Reader{strings.NewReader("1 1\n"),
+    strings.NewReader("3 -1\n")}
+  result_17, err := FromReaders(inputs, []string{"a", "b"}, opts)
+  if err == nil || !float_array_equal(result_17[0], []float64{1.0}) {
+    t.Errorf("Parse test 17: Failed to reject negative weight")
+  }
+}
+
+
+// Tests for combining files in input order independent of the order in
+// which the workers finish
+func Test_Average_9(t *testing.T) {
+
+  short := "test_files/test_data_8.txt"
+  long_1, long_2 := "test_files/test_data_1.txt", "test_files/test_data_2.txt"
+  opts := Options{Columns: []parser.Column{{Index: 0}},
+    Parse: parser.DefaultConfig()}
+  for i := 0; i < 20; i++ {
+    result_18, err := Average([]string{short, long_1, long_2}, opts, 3)
+    errs, ok := err.(parser.Errors)
+    if !ok || len(errs) != 2 ||
+       !float_columns_equal(result_18, [][]float64{{3.0, 3.0, 3.0}}) {
+      t.Fatalf("Parse test 18: Failed to keep the first file - got %v",
+        result_18)
+    }
+
+    result_19, err := Average([]string{long_1, short, long_2}, opts, 3)
+    errs, ok = err.(parser.Errors)
+    if !ok || len(errs) != 1 || !float_columns_equal(result_19,
+      [][]float64{{1.5, 1.5, 1.5, 1.5}}) {
+      t.Fatalf("Parse test 19: Failed to keep the first file - got %v",
+        result_19)
+    }
+  }
+}
+
+
+// Tests for reporting failed files held back for interpolation
+func Test_Average_10(t *testing.T) {
+
+  inputs := []io.Reader{strings.NewReader("0 1\n1 2\n"),
+    strings.NewReader("0 3\n1 4\n"), strings.NewReader("0 x\n")}
+  opts := Options{Columns: []parser.Column{{Index: 1}},
+    Reference: &parser.Column{Index: 0}, Align: AlignInterpolate,
+    Parse: parser.DefaultConfig()}
+  result_20, err := FromReaders(inputs, nil, opts)
+  errs, ok := err.(parser.Errors)
+  if !ok || len(errs) != 1 ||
+     !float_columns_equal(result_20, [][]float64{{0, 1}, {2, 3}}) {
+    t.Errorf("Parse test 20: Failed to report invalid file - got %v (%v)",
+      result_20, err)
+  }
+}
+
+
+// Tests for leaving skipped missing values out of the average of their
+// row only
+func Test_Average_11(t *testing.T) {
+
+  conf := parser.DefaultConfig()
+  conf.Missing = parser.MissingSkip
+  inputs := []io.Reader{strings.NewReader("1\nNA\n3\n"),
+    strings.NewReader("1\n2\n3\n")}
+  opts := Options{Columns: []parser.Column{{Index: 0}}, Envelope: true,
+    Parse: conf}
+  result_21, err := FromReaders(inputs, nil, opts)
+  expected_21 := [][]float64{{1, 2, 3}, {1, 2, 3}, {1, 2, 3}}
+  if err != nil || !float_columns_equal(result_21, expected_21) {
+    t.Errorf("Parse test 21: Failed to skip missing value - got %v (%v)",
+      result_21, err)
+  }
+
+  inputs = []io.Reader{strings.NewReader("0 1\n1 NA\n2 3\n"),
+    strings.NewReader("0 1\n1 2\n2 3\n"), strings.NewReader("0 1\nNA 2\n")}
+  opts = Options{Columns: []parser.Column{{Index: 1}},
+    Reference: &parser.Column{Index: 0}, Align: AlignInterpolate,
+    Parse: conf}
+  result_22, err := FromReaders(inputs, nil, opts)
+  errs, ok := err.(parser.Errors)
+  if !ok || len(errs) != 1 ||
+     !float_columns_equal(result_22, [][]float64{{0, 1, 2}, {1, 2, 3}}) {
+    t.Errorf("Parse test 22: Failed to interpolate missing value - got " +
+      "%v (%v)", result_22, err)
+  }
+
+  opts = Options{Columns: []parser.Column{{Index: 0}, {Index: 1}},
+    Parse: conf}
+  result_23, err := ReadColumns(strings.NewReader("1 2\nNA 3\n4 5\n"),
+    "buffer", opts)
+  if err != nil ||
+     !float_columns_equal(result_23, [][]float64{{1, 4}, {2, 5}}) {
+    t.Errorf("Parse test 23: Failed to skip row - got %v (%v)", result_23,
+      err)
+  }
+}
+
+
+// float_columns_equal compares two lists of float arrays for equality
+func float_columns_equal(a1, a2 [][]float64) bool {
+  if len(a1) != len(a2) {
+    return false
+  }
+
+  for i, v := range a1 {
+    if !float_array_equal(v, a2[i]) {
+      return false
+    }
+  }
+
+  return true
+}
+
+
+
 // float_array_equal compares to arrays of float for equality
 // NOTE: the floating point comparison is currently based on
 // the smallest representable float which is probabably not
@@ -0,0 +1,200 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package blocking estimates the standard error of the mean of correlated
+// time series, e.g., from molecular dynamics or Monte Carlo simulations,
+// via the block averaging method of Flyvbjerg and Petersen. The standard
+// error assuming independent samples (as reported by package statistic)
+// underestimates the error of correlated data.
+//
+// The analysis of a single input stream is computed via FromReader,
+// Analyses processes a list of files concurrently.
+//
+// NOTE: see H. Flyvbjerg and H. G. Petersen, J. Chem. Phys. 91, 461
+//       (1989)
+//
+package blocking
+
+import (
+  "io"
+  "math"
+  "github.com/haskelladdict/lizard/parser"
+  "github.com/haskelladdict/lizard/pool"
+  "github.com/haskelladdict/lizard/statistic"
+)
+
+
+
+// Options describes the columns to analyze and how to parse the input
+type Options struct {
+  Columns []parser.Column
+  Parse parser.Config
+}
+
+
+
+// Level describes the standard error of the mean estimated from blocks of
+// BlockSize consecutive samples. The estimate is reliable once the block
+// size exceeds the correlation time of the data.
+type Level struct {
+  BlockSize int
+  Blocks int
+  StdErr float64
+  StdErrError float64   // statistical error of StdErr
+}
+
+
+
+// Analysis describes the block analysis of a single column of a file.
+// The levels double the block size starting at 1. The standard error of
+// the mean is taken from the first level on the plateau, i.e., whose
+// block size is large compared to the correlation time.
+type Analysis struct {
+  Name string
+  Column string
+  Mean float64
+  Count int           // number of samples
+  Ignored int         // number of NaN or infinite samples not used
+  Levels []Level
+  Plateau int         // index of the plateau level, -1 if none was reached
+  StdErr float64      // standard error on the plateau, NaN if none
+  StdErrError float64
+}
+
+
+
+// FromReader computes the block analysis of each requested column of a
+// plain text column oriented data stream. The columns are read as by
+// statistic.ReadColumns, each column is taken as a time series in the
+// order of the rows. The name of the input is used for error reporting
+// and the Name of the returned analyses.
+func FromReader(r io.Reader, name string, opts Options) ([]Analysis,
+  error) {
+
+  cols, err := statistic.ReadColumns(r, name,
+    statistic.Options{Columns: opts.Columns, Parse: opts.Parse})
+  if err != nil {
+    return nil, err
+  }
+
+  analyses := make([]Analysis, len(cols))
+  for i, col := range cols {
+    analyses[i] = New(col)
+    analyses[i].Name = parser.Label(name)
+    analyses[i].Column = opts.Columns[i].String()
+  }
+  return analyses, nil
+}
+
+
+
+// New computes the block analysis of the time series data. NaN and
+// infinite values are dropped and counted as Ignored. At each level
+// pairs of neighbouring blocks are averaged, a trailing odd block is
+// dropped. The levels end once fewer than two blocks remain.
+func New(data []float64) Analysis {
+
+  blocks := make([]float64, 0, len(data))
+  for _, v := range data {
+    if !math.IsNaN(v) && !math.IsInf(v, 0) {
+      blocks = append(blocks, v)
+    }
+  }
+
+  a := Analysis{Count: len(blocks), Ignored: len(data) - len(blocks),
+    Mean: math.NaN(), Plateau: -1, StdErr: math.NaN(),
+    StdErrError: math.NaN()}
+  if len(blocks) != 0 {
+    a.Mean = mean(blocks)
+  }
+
+  for size := 1; len(blocks) >= 2; size *= 2 {
+    n := float64(len(blocks))
+    m := mean(blocks)
+    c0 := 0.0
+    for _, v := range blocks {
+      c0 += (v - m)*(v - m)
+    }
+    stderr := math.Sqrt(c0/(n*(n - 1)))
+    a.Levels = append(a.Levels, Level{BlockSize: size, Blocks: len(blocks),
+      StdErr: stderr, StdErrError: stderr/math.Sqrt(2.0*(n - 1))})
+
+    half := len(blocks)/2
+    for i := 0; i < half; i++ {
+      blocks[i] = 0.5*(blocks[2*i] + blocks[2*i+1])
+    }
+    blocks = blocks[:half]
+  }
+
+  if a.Plateau = plateau(a.Levels, a.Count); a.Plateau >= 0 {
+    a.StdErr = a.Levels[a.Plateau].StdErr
+    a.StdErrError = a.Levels[a.Plateau].StdErrError
+  }
+  return a
+}
+
+
+
+// plateau returns the index of the first level whose block size B
+// satisfies B^3 > 2 n (s_B/s_1)^4 with s_B the standard error at block
+// size B and n the number of samples, or -1 if there is none. Since
+// (s_B/s_1)^2 estimates the correlation time this ensures blocks much
+// longer than the correlation time.
+//
+// NOTE: see M. Wolff, Comput. Phys. Commun. 156, 143 (2004) and
+//       R. M. Lee et al., Phys. Rev. E 83, 066706 (2011)
+func plateau(levels []Level, n int) int {
+
+  if len(levels) == 0 {
+    return -1
+  } else if levels[0].StdErr == 0.0 {
+    return 0
+  }
+
+  for i, l := range levels {
+    b := float64(l.BlockSize)
+    ratio := l.StdErr/levels[0].StdErr
+    if b*b*b > 2.0*float64(n)*math.Pow(ratio, 4) {
+      return i
+    }
+  }
+  return -1
+}
+
+
+
+// mean returns the mean of data
+func mean(data []float64) float64 {
+  sum := 0.0
+  for _, v := range data {
+    sum += v
+  }
+  return sum/float64(len(data))
+}
+
+
+
+// Analyses computes the block analysis of the requested columns of each
+// file using numWorkers concurrent workers. There is one analysis per
+// file and column ordered by file as in fileNames and then by column.
+//
+// NOTE: An empty file name denotes stdin.
+//
+// NOTE: Files which can not be opened or parsed are ignored. Their errors
+//       are returned as parser.Errors alongside the analyses of all other
+//       files.
+func Analyses(fileNames []string, opts Options,
+  numWorkers int) ([]Analysis, error) {
+
+  analyses, err := pool.Process(fileNames, numWorkers,
+    func(r io.Reader, name string) ([]Analysis, error) {
+      return FromReader(r, name, opts)
+    })
+
+  output := make([]Analysis, 0)
+  for _, a := range analyses {
+    output = append(output, a...)
+  }
+  return output, err
+}
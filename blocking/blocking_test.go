@@ -0,0 +1,115 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package blocking estimates the standard error of the mean of correlated
+// time series via block averaging.
+package blocking
+
+import (
+  "math"
+  "math/rand"
+  "testing"
+  "github.com/haskelladdict/lizard/parser"
+)
+
+
+// Tests for the standard error at each blocking level
+func Test_Blocking_1(t *testing.T) {
+
+  result_1 := New([]float64{1, 2, 3, math.NaN(), 4, 7})
+  if result_1.Count != 5 || result_1.Ignored != 1 ||
+     !float_equal(result_1.Mean, 3.4) || len(result_1.Levels) != 2 {
+    t.Fatalf("Blocking test 1 failed - got %v", result_1)
+  }
+
+  // the trailing odd sample is dropped when blocking
+  l0, l1 := result_1.Levels[0], result_1.Levels[1]
+  if l0.BlockSize != 1 || l0.Blocks != 5 ||
+     !float_equal(l0.StdErr, math.Sqrt(21.2/20.0)) ||
+     !float_equal(l0.StdErrError, l0.StdErr/math.Sqrt(8.0)) ||
+     l1.BlockSize != 2 || l1.Blocks != 2 || !float_equal(l1.StdErr, 1.0) {
+    t.Errorf("Blocking test 1 failed - got levels %v", result_1.Levels)
+  }
+
+  // five samples are too few to reach a plateau
+  if result_1.Plateau != -1 || !math.IsNaN(result_1.StdErr) {
+    t.Errorf("Blocking test 1 failed - unexpected plateau %v", result_1)
+  }
+
+  result_2 := New([]float64{2, 2, 2, 2})
+  if result_2.Plateau != 0 || result_2.StdErr != 0.0 {
+    t.Errorf("Blocking test 2 failed - got %v", result_2)
+  }
+}
+
+
+// Tests for the detection of the plateau of uncorrelated and correlated
+// time series
+func Test_Blocking_2(t *testing.T) {
+
+  r := rand.New(rand.NewSource(1))
+  data := make([]float64, 1 << 16)
+  for i := range data {
+    data[i] = r.NormFloat64()
+  }
+
+  // for uncorrelated samples the plateau criterion reduces to B^3 > 2 n
+  result_3 := New(data)
+  if result_3.Plateau != 6 ||
+     math.Abs(result_3.StdErr - 1.0/256.0) > 3.0*result_3.StdErrError {
+    t.Errorf("Blocking test 3 failed - got plateau %d with error %v",
+      result_3.Plateau, result_3.StdErr)
+  }
+
+  // an AR(1) process x_i = phi x_(i-1) + e_i has a standard error of the
+  // mean larger by a factor of sqrt((1 + phi)/(1 - phi))
+  phi := 0.9
+  data[0] = 0.0
+  for i := 1; i < len(data); i++ {
+    data[i] = phi*data[i-1] + r.NormFloat64()
+  }
+  result_4 := New(data)
+  naive := result_4.Levels[0].StdErr
+  expected := naive*math.Sqrt((1.0 + phi)/(1.0 - phi))
+  if result_4.Plateau <= result_3.Plateau ||
+     math.Abs(result_4.StdErr - expected) > 0.15*expected {
+    t.Errorf("Blocking test 4 failed - got plateau %d with error %v " +
+      "(expected %v)", result_4.Plateau, result_4.StdErr, expected)
+  }
+}
+
+
+// Tests for processing several files concurrently
+func Test_Blocking_3(t *testing.T) {
+
+  conf := parser.DefaultConfig()
+  data_files := []string{"test_files/does_not_exist.txt",
+    "test_files/test_data_1.txt"}
+  opts := Options{Columns: []parser.Column{{Index: 1}, {Index: 0}},
+    Parse: conf}
+  result_5, err := Analyses(data_files, opts, 4)
+  if errs, ok := err.(parser.Errors); !ok || len(errs) != 1 {
+    t.Errorf("Blocking test 5 failed - unexpected error %v", err)
+  }
+  if len(result_5) != 2 || result_5[0].Name != data_files[1] ||
+     result_5[0].Column != "1" || !float_equal(result_5[0].Mean, 2.5) ||
+     !float_equal(result_5[1].Mean, 1.5) ||
+     !float_equal(result_5[0].Levels[1].StdErr, 1.0) {
+    t.Errorf("Blocking test 5 failed - got %v", result_5)
+  }
+}
+
+
+// Support Functions
+//
+// float_equal compares two float numbers for equality
+// NOTE: the floating point comparison is based on an epsilon
+//       which was chosen empirically so its not rigorous
+func float_equal(a1, a2 float64) bool {
+  epsilon := 1e-13
+  if math.Abs(a2-a1) > epsilon * math.Abs(a1) {
+    return false
+  }
+  return true
+}
@@ -0,0 +1,84 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+  "fmt"
+  "log"
+  "math"
+  "os"
+  "strings"
+  "github.com/haskelladdict/lizard/autocorr"
+  "github.com/haskelladdict/lizard/output"
+  "github.com/haskelladdict/lizard/statistic"
+)
+
+
+
+// run_autocorr computes the autocorrelation function and integrated
+// autocorrelation time of the requested columns of each input file
+func run_autocorr(args []string) {
+
+  fs, common := new_flag_set("acf", "Treats the requested columns of " +
+    "each file as time series and prints their normalised " +
+    "autocorrelation function together with the integrated " +
+    "autocorrelation time tau and the number of independent samples " +
+    "n/tau.")
+  lags := fs.Int("lags", 0,
+    "largest lag printed (default: the automatic window of tau)")
+  window := fs.Float64("window", autocorr.DefaultWindow,
+    "factor c of the automatic window M >= c tau of the autocorrelation " +
+    "time (default: 5)")
+  s := common.settings(fs, args, "%8.6f")
+
+  opts := statistic.Options{Columns: s.columns, Correlation: true,
+    MaxLag: *lags, Window: *window, Parse: s.conf}
+  stats, err := statistic.Statistic(s.files, opts, s.numWorkers)
+  report_errors(err, s.strict)
+  print_autocorr(stats, s)
+}
+
+
+
+// print_autocorr prints the autocorrelation functions in the requested
+// output format. In text format each function is preceded by its
+// statistics as comment lines and separated from the next one by a blank
+// line. Otherwise each row also carries tau and its window (0 if the
+// automatic window was not reached).
+func print_autocorr(stats []statistic.Stat, s settings) {
+
+  if s.format == output.Text {
+    f := s.floats.Format
+    for i, stat := range stats {
+      if i != 0 {
+        fmt.Println()
+      }
+      pad := "#" + strings.Repeat(" ", len(stat.Name) + len(stat.Column) + 3)
+      fmt.Printf("# %s [%s]: %s +/- %s  (mean +/- std)\n", stat.Name,
+        stat.Column, f(stat.Mean), f(math.Sqrt(stat.Variance)))
+      fmt.Printf("%s   %d  (samples)\n", pad, stat.Count)
+      print_tau(pad, stat, s.floats)
+      for lag, v := range stat.ACF {
+        fmt.Printf("%d %s\n", lag, f(v))
+      }
+    }
+    return
+  }
+
+  w := output.NewWriter(os.Stdout, s.format,
+    []string{"file", "column", "lag", "acf", "tau", "tau_window"})
+  w.SetFloatFormat(s.floats)
+  for _, stat := range stats {
+    for lag, v := range stat.ACF {
+      if err := w.Write(stat.Name, stat.Column, lag, v, stat.Tau,
+        stat.TauWindow); err != nil {
+        log.Fatal(err)
+      }
+    }
+  }
+  if err := w.Flush(); err != nil {
+    log.Fatal(err)
+  }
+}
@@ -0,0 +1,155 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+  "fmt"
+  "io"
+  "log"
+  "os"
+  "github.com/haskelladdict/lizard/average"
+  "github.com/haskelladdict/lizard/output"
+  "github.com/haskelladdict/lizard/parser"
+)
+
+
+
+// run_average averages the requested columns row by row across all input
+// files
+func run_average(args []string) {
+
+  fs, common := new_flag_set("average", "Averages the requested columns " +
+    "row by row across files and optionally reports the spread of each " +
+    "row.")
+  spread := fs.Bool("spread", false,
+    "add the row wise variance, std and standard error across files to " +
+    "each column (default: false)")
+  envelope := fs.Bool("envelope", false,
+    "add the row wise minimum and maximum across files to each column " +
+    "(default: false)")
+  reference := fs.String("x", "",
+    "id or header name of a reference column (e.g. time) printed as the " +
+    "first column; must be identical across files")
+  alignMode := fs.String("align", "strict",
+    "handling of files with differing number of rows: strict, truncate, " +
+    "pad (with NaN) or interpolate (onto the -x column of the first file)")
+  weight := fs.String("weight", "",
+    "id or header name of a column of non-negative weights of each " +
+    "file's rows")
+  splitBlocks := fs.String("split", "",
+    "split each input (e.g. stdin) into separate series at blank lines " +
+    "(blank) or at lines starting with the given prefix")
+  s := common.settings(fs, args, "%8.4f")
+
+  align, err := average.ParseAlignMode(*alignMode)
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  opts := average.Options{Columns: s.columns, Spread: *spread,
+    Envelope: *envelope, Reference: optional_column(*reference),
+    Align: align, Weight: optional_column(*weight), Parse: s.conf}
+
+  numWorkers := s.numWorkers
+  if len(s.files) < numWorkers {
+    numWorkers = len(s.files)
+  }
+
+  var avg [][]float64
+  if *splitBlocks != "" {
+    avg, err = average_blocks(s.files, *splitBlocks, opts)
+  } else {
+    avg, err = average.Average(s.files, opts, numWorkers)
+  }
+  report_errors(err, s.strict)
+  print_average(avg, opts, s)
+}
+
+
+
+// print_average prints the averaged columns row by row in the requested
+// output format
+func print_average(avg [][]float64, opts average.Options, s settings) {
+
+  if len(avg) == 0 {
+    return
+  }
+
+  if s.format == output.Text {
+    for i := range avg[0] {
+      for c, col := range avg {
+        if c != 0 {
+          fmt.Print(" ")
+        }
+        fmt.Print(s.floats.Format(col[i]))
+      }
+      fmt.Println()
+    }
+    return
+  }
+
+  w := output.NewWriter(os.Stdout, s.format, opts.Labels())
+  w.SetFloatFormat(s.floats)
+  values := make([]interface{}, len(avg))
+  for i := range avg[0] {
+    for c, col := range avg {
+      values[c] = col[i]
+    }
+    if err := w.Write(values...); err != nil {
+      log.Fatal(err)
+    }
+  }
+  if err := w.Flush(); err != nil {
+    log.Fatal(err)
+  }
+}
+
+
+
+// average_blocks splits each input into separate series at blank lines
+// (separator "blank") or at lines starting with separator and averages
+// all of them
+func average_blocks(fileNames []string, separator string,
+  opts average.Options) ([][]float64, error) {
+
+  if separator == "blank" {
+    separator = ""
+  }
+
+  var readers []io.Reader
+  var names []string
+  var errors parser.Errors
+  for _, fileName := range fileNames {
+    file, err := parser.Open(fileName)
+    if err != nil {
+      errors = append(errors, err)
+      continue
+    }
+    name := parser.Label(fileName)
+
+    blocks, err := parser.SplitBlocks(file, separator)
+    file.Close()
+    if err != nil {
+      errors = append(errors, fmt.Errorf("%s: %w", name, err))
+      continue
+    }
+    for i, block := range blocks {
+      readers = append(readers, block)
+      names = append(names, fmt.Sprintf("%s (block %d)", name, i+1))
+    }
+  }
+
+  avg, err := average.FromReaders(readers, names, opts)
+  if errs, ok := err.(parser.Errors); ok {
+    errors = append(errors, errs...)
+  } else if err != nil {
+    return nil, err
+  }
+
+  if len(errors) != 0 {
+    return avg, errors
+  }
+  return avg, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+  "fmt"
+  "log"
+  "os"
+  "github.com/haskelladdict/lizard/blocking"
+  "github.com/haskelladdict/lizard/output"
+)
+
+
+
+// run_blocking computes the block averaging analysis of the requested
+// columns of each input file
+func run_blocking(args []string) {
+
+  fs, common := new_flag_set("block", "Estimates the standard error of " +
+    "the mean of correlated time series via Flyvbjerg-Petersen block " +
+    "averaging. Prints the standard error as a function of the block " +
+    "size and the value on the automatically detected plateau.")
+  s := common.settings(fs, args, "%8.8f")
+
+  opts := blocking.Options{Columns: s.columns, Parse: s.conf}
+  analyses, err := blocking.Analyses(s.files, opts, s.numWorkers)
+  report_errors(err, s.strict)
+  print_analyses(analyses, s)
+}
+
+
+
+// print_analyses prints the block analyses in the requested output
+// format. In text format each analysis is preceded by a comment line,
+// followed by the plateau value and separated from the next one by a
+// blank line.
+func print_analyses(analyses []blocking.Analysis, s settings) {
+
+  f := s.floats.Format
+  if s.format == output.Text {
+    for i, a := range analyses {
+      if i != 0 {
+        fmt.Println()
+      }
+      fmt.Printf("# %s [%s]: %d samples, mean %s\n", a.Name, a.Column,
+        a.Count, f(a.Mean))
+      fmt.Printf("# block size, blocks, standard error, error of standard " +
+        "error\n")
+      for _, l := range a.Levels {
+        fmt.Printf("%d %d %s %s\n", l.BlockSize, l.Blocks, f(l.StdErr),
+          f(l.StdErrError))
+      }
+      if a.Plateau < 0 {
+        fmt.Printf("# no plateau reached, the time series is too short\n")
+      } else {
+        fmt.Printf("# plateau at block size %d: standard error %s +/- %s\n",
+          a.Levels[a.Plateau].BlockSize, f(a.StdErr), f(a.StdErrError))
+      }
+    }
+    return
+  }
+
+  w := output.NewWriter(os.Stdout, s.format, []string{"file", "column",
+    "block_size", "blocks", "stderr", "stderr_error", "plateau"})
+  w.SetFloatFormat(s.floats)
+  for _, a := range analyses {
+    for i, l := range a.Levels {
+      if err := w.Write(a.Name, a.Column, l.BlockSize, l.Blocks, l.StdErr,
+        l.StdErrError, i == a.Plateau); err != nil {
+        log.Fatal(err)
+      }
+    }
+  }
+  if err := w.Flush(); err != nil {
+    log.Fatal(err)
+  }
+}
@@ -0,0 +1,81 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+  "fmt"
+  "log"
+  "os"
+  "github.com/haskelladdict/lizard/histogram"
+  "github.com/haskelladdict/lizard/output"
+)
+
+
+
+// run_histogram computes the histogram of the requested columns of each
+// input file
+func run_histogram(args []string) {
+
+  fs, common := new_flag_set("hist", "Bins the requested columns of each " +
+    "file and prints bin centers, counts and the density normalised to " +
+    "unit area.")
+  bins := fs.Int("bins", 0,
+    "number of bins (default: chosen via -rule)")
+  width := fs.Float64("width", 0.0,
+    "width of the bins (default: chosen via -rule)")
+  rule := fs.String("rule", "fd",
+    "automatic choice of the bins: fd (Freedman-Diaconis) or sturges " +
+    "(default: fd)")
+  s := common.settings(fs, args, "%8.4f")
+
+  binRule, err := histogram.ParseRule(*rule)
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  opts := histogram.Options{Columns: s.columns, Bins: *bins, Width: *width,
+    Rule: binRule, Parse: s.conf}
+  hists, err := histogram.Histograms(s.files, opts, s.numWorkers)
+  report_errors(err, s.strict)
+  print_histograms(hists, s)
+}
+
+
+
+// print_histograms prints the histograms in the requested output format.
+// In text format each histogram is preceded by a comment line and
+// separated from the next one by a blank line.
+func print_histograms(hists []histogram.Histogram, s settings) {
+
+  if s.format == output.Text {
+    f := s.floats.Format
+    for i, h := range hists {
+      if i != 0 {
+        fmt.Println()
+      }
+      fmt.Printf("# %s [%s]: %d samples, bin width %s\n", h.Name, h.Column,
+        h.Count, f(h.Width))
+      for b, c := range h.Counts {
+        fmt.Printf("%s %d %s\n", f(h.Centers[b]), c, f(h.Density[b]))
+      }
+    }
+    return
+  }
+
+  w := output.NewWriter(os.Stdout, s.format,
+    []string{"file", "column", "center", "count", "density"})
+  w.SetFloatFormat(s.floats)
+  for _, h := range hists {
+    for b, c := range h.Counts {
+      if err := w.Write(h.Name, h.Column, h.Centers[b], c,
+        h.Density[b]); err != nil {
+        log.Fatal(err)
+      }
+    }
+  }
+  if err := w.Flush(); err != nil {
+    log.Fatal(err)
+  }
+}
@@ -0,0 +1,101 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+  "fmt"
+  "log"
+  "os"
+  "strconv"
+  "github.com/haskelladdict/lizard/kde"
+  "github.com/haskelladdict/lizard/output"
+)
+
+
+
+// run_kde computes the kernel density estimate of the requested columns
+// of each input file
+func run_kde(args []string) {
+
+  fs, common := new_flag_set("kde", "Computes kernel density estimates " +
+    "of the requested columns of each file on an evenly spaced grid and " +
+    "prints grid points and density.")
+  kernel := fs.String("kernel", "gaussian",
+    "smoothing kernel: gaussian or epanechnikov (default: gaussian)")
+  bandwidth := fs.String("bw", "silverman",
+    "bandwidth (standard deviation of the kernel) or rule of thumb: " +
+    "silverman or scott (default: silverman)")
+  points := fs.Int("n", kde.DefaultPoints,
+    "number of grid points (default: 512)")
+  gridMin := fs.Float64("from", 0.0,
+    "lower end of the grid (default: 3 bandwidths below the minimum)")
+  gridMax := fs.Float64("to", 0.0,
+    "upper end of the grid (default: 3 bandwidths above the maximum)")
+  s := common.settings(fs, args, "%8.6f")
+
+  k, err := kde.ParseKernel(*kernel)
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  opts := kde.Options{Columns: s.columns, Kernel: k, Points: *points,
+    Parse: s.conf}
+  if h, err := strconv.ParseFloat(*bandwidth, 64); err == nil {
+    opts.Bandwidth = h
+  } else if opts.Rule, err = kde.ParseRule(*bandwidth); err != nil {
+    log.Fatal(err)
+  }
+
+  // NOTE: the grid is only used if both ends were given
+  if is_set(fs, "from") != is_set(fs, "to") {
+    log.Fatal("the grid requires both -from and -to")
+  } else if is_set(fs, "from") && !(*gridMin < *gridMax) {
+    log.Fatalf("the grid requires -from %v to be below -to %v", *gridMin,
+      *gridMax)
+  }
+  opts.Grid = is_set(fs, "from")
+  opts.GridMin, opts.GridMax = *gridMin, *gridMax
+
+  estimates, err := kde.Estimates(s.files, opts, s.numWorkers)
+  report_errors(err, s.strict)
+  print_estimates(estimates, s)
+}
+
+
+
+// print_estimates prints the density estimates in the requested output
+// format. In text format each estimate is preceded by a comment line and
+// separated from the next one by a blank line.
+func print_estimates(estimates []kde.Estimate, s settings) {
+
+  if s.format == output.Text {
+    f := s.floats.Format
+    for i, e := range estimates {
+      if i != 0 {
+        fmt.Println()
+      }
+      fmt.Printf("# %s [%s]: %d samples, bandwidth %s\n", e.Name, e.Column,
+        e.Count, f(e.Bandwidth))
+      for p, x := range e.X {
+        fmt.Printf("%s %s\n", f(x), f(e.Density[p]))
+      }
+    }
+    return
+  }
+
+  w := output.NewWriter(os.Stdout, s.format,
+    []string{"file", "column", "x", "density"})
+  w.SetFloatFormat(s.floats)
+  for _, e := range estimates {
+    for p, x := range e.X {
+      if err := w.Write(e.Name, e.Column, x, e.Density[p]); err != nil {
+        log.Fatal(err)
+      }
+    }
+  }
+  if err := w.Flush(); err != nil {
+    log.Fatal(err)
+  }
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+  "fmt"
+  "log"
+  "math"
+  "os"
+  "strconv"
+  "strings"
+  "github.com/haskelladdict/lizard/autocorr"
+  "github.com/haskelladdict/lizard/output"
+  "github.com/haskelladdict/lizard/sketch"
+  "github.com/haskelladdict/lizard/statistic"
+)
+
+
+
+// run_stats computes the statistics of the requested columns of each
+// input file
+func run_stats(args []string) {
+
+  fs, common := new_flag_set("stats", "Computes mean, standard " +
+    "deviation, higher moments and optionally median and quantiles of " +
+    "the requested columns of each file.")
+  wantMedian := fs.Bool("m", false,
+    "compute the median (default: false)\n" +
+    "NOTE: the median is O(n) on average and requires memory the size " +
+    "of the data column")
+  quantiles := fs.String("q", "",
+    "comma separated list of quantiles to compute, e.g. 0.05,0.95 (same " +
+    "cost as the median)")
+  quantileMethod := fs.Int("qtype", statistic.DefaultQuantileMethod,
+    "sample quantile definition 1 - 9 as in R (default: 7)")
+  approximate := fs.Bool("approx", false,
+    "estimate median and quantiles in bounded memory via a KLL sketch " +
+    "(default: false)")
+  sketchK := fs.Int("k", sketch.DefaultK,
+    "accuracy parameter of the KLL sketch used with -approx (default: 200)")
+  weight := fs.String("weight", "",
+    "id or header name of a column of non-negative row weights for " +
+    "weighted moments")
+  wantTau := fs.Bool("tau", false,
+    "treat columns as time series and compute the integrated " +
+    "autocorrelation time and the number of independent samples " +
+    "(default: false)")
+  window := fs.Float64("window", autocorr.DefaultWindow,
+    "factor c of the automatic window M >= c tau of the autocorrelation " +
+    "time (default: 5)")
+  s := common.settings(fs, args, "%8.8f")
+
+  probs, err := parse_floats(*quantiles)
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  opts := statistic.Options{Columns: s.columns, Median: *wantMedian,
+    Quantiles: probs, QuantileMethod: *quantileMethod,
+    Approximate: *approximate, SketchK: *sketchK,
+    Weight: optional_column(*weight), Correlation: *wantTau,
+    Window: *window, Parse: s.conf}
+
+  // NOTE: Surplus workers are used to process large files in chunks
+  stats, err := statistic.Statistic(s.files, opts, s.numWorkers)
+  report_errors(err, s.strict)
+  print_stats(stats, opts, s)
+}
+
+
+
+// print_stats prints the file statistics in the requested output format
+func print_stats(stats []statistic.Stat, opts statistic.Options,
+  s settings) {
+
+  if s.format == output.Text {
+    print_stats_text(stats, opts, s.floats)
+    return
+  }
+
+  fields := []string{"file", "column", "count", "mean", "std", "variance",
+    "stderr", "min", "max", "skewness", "kurtosis", "skipped"}
+  if opts.Weight != nil {
+    fields = append(fields, "effective_size")
+  }
+  if opts.Median {
+    fields = append(fields, "median")
+  }
+  for _, p := range opts.Quantiles {
+    fields = append(fields, "q" + strconv.FormatFloat(p, 'g', -1, 64))
+  }
+  if len(opts.Quantiles) != 0 {
+    fields = append(fields, "iqr")
+  }
+  if opts.Approximate && (opts.Median || len(opts.Quantiles) != 0) {
+    fields = append(fields, "rank_error")
+  }
+  if opts.Correlation {
+    fields = append(fields, "tau", "tau_window", "independent_size")
+  }
+
+  w := output.NewWriter(os.Stdout, s.format, fields)
+  w.SetFloatFormat(s.floats)
+  for _, stat := range stats {
+    values := []interface{}{stat.Name, stat.Column, stat.Count, stat.Mean,
+      math.Sqrt(stat.Variance), stat.Variance, stat.StdErr, stat.Min,
+      stat.Max, stat.Skewness, stat.Kurtosis, stat.Skipped}
+    if opts.Weight != nil {
+      values = append(values, stat.EffectiveSize)
+    }
+    if opts.Median {
+      values = append(values, stat.Median)
+    }
+    for _, q := range stat.Quantiles {
+      values = append(values, q)
+    }
+    if len(opts.Quantiles) != 0 {
+      values = append(values, stat.IQR)
+    }
+    if opts.Approximate && (opts.Median || len(opts.Quantiles) != 0) {
+      values = append(values, stat.RankError)
+    }
+    if opts.Correlation {
+      values = append(values, stat.Tau, stat.TauWindow,
+        stat.IndependentSize)
+    }
+    if err := w.Write(values...); err != nil {
+      log.Fatal(err)
+    }
+  }
+  if err := w.Flush(); err != nil {
+    log.Fatal(err)
+  }
+}
+
+
+
+// print_stats_text prints the file statistics in human readable form
+func print_stats_text(stats []statistic.Stat, opts statistic.Options,
+  floats output.FloatFormat) {
+
+  f := floats.Format
+  for _, stat := range stats {
+    // label results by column if more than one column was requested
+    name := stat.Name
+    if len(opts.Columns) > 1 {
+      name = fmt.Sprintf("%s [%s]", stat.Name, stat.Column)
+    }
+
+    pad := strings.Repeat(" ", len(name))
+    fmt.Printf("%s : %s +/- %s  (mean +/- std)\n", name, f(stat.Mean),
+      f(math.Sqrt(stat.Variance)))
+    fmt.Printf("%s   %s  (standard error)\n", pad, f(stat.StdErr))
+    if opts.Median {
+      fmt.Printf("%s   %s  (median)\n", pad, f(stat.Median))
+    }
+    for i, q := range stat.Quantiles {
+      fmt.Printf("%s   %s  (%g quantile)\n", pad, f(q), opts.Quantiles[i])
+    }
+    if len(stat.Quantiles) != 0 {
+      fmt.Printf("%s   %s  (interquartile range)\n", pad, f(stat.IQR))
+    }
+    if stat.RankError != 0 {
+      fmt.Printf("%s   %s  (rank error of approximate order statistics)\n",
+        pad, f(stat.RankError))
+    }
+    fmt.Printf("%s   %s / %s  (min / max)\n", pad, f(stat.Min), f(stat.Max))
+    fmt.Printf("%s   %s / %s  (skewness / excess kurtosis)\n", pad,
+      f(stat.Skewness), f(stat.Kurtosis))
+    fmt.Printf("%s   %d  (samples)\n", pad, stat.Count)
+    if opts.Weight != nil {
+      fmt.Printf("%s   %s  (effective sample size)\n", pad,
+        f(stat.EffectiveSize))
+    }
+    if opts.Correlation {
+      print_tau(pad, stat, floats)
+    }
+    if stat.Skipped != 0 {
+      fmt.Printf("%s   %d missing values skipped\n", pad, stat.Skipped)
+    }
+  }
+}
+
+
+
+// print_tau prints the integrated autocorrelation time and the number of
+// independent samples of a time series in human readable form
+func print_tau(pad string, stat statistic.Stat, floats output.FloatFormat) {
+
+  f := floats.Format
+  fmt.Printf("%s   %s  (integrated autocorrelation time)\n", pad,
+    f(stat.Tau))
+  fmt.Printf("%s   %s  (independent samples)\n", pad,
+    f(stat.IndependentSize))
+  if math.IsNaN(stat.Tau) {
+    fmt.Printf("%s   WARNING: no autocorrelation time estimate, the time " +
+      "series is too short or too noisy\n", pad)
+  } else if stat.TauWindow == 0 {
+    fmt.Printf("%s   WARNING: automatic window not reached, the time " +
+      "series is too short\n", pad)
+  }
+}
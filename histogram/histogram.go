@@ -0,0 +1,278 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package histogram bins the columns of column based text files into
+// histograms of equal width bins. The bins are chosen either via a fixed
+// number of bins, a fixed bin width or automatically via the Freedman-
+// Diaconis or Sturges rule.
+//
+// The histogram of a single input stream is computed via FromReader,
+// Histograms processes a list of files concurrently.
+//
+package histogram
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "math"
+  "strings"
+  "github.com/haskelladdict/lizard/parser"
+  "github.com/haskelladdict/lizard/pool"
+  "github.com/haskelladdict/lizard/statistic"
+)
+
+
+
+// MaxBins is the largest number of bins of a histogram
+const MaxBins = 1 << 20
+
+
+
+// ErrTooManyBins is reported if the requested bin width would require
+// more than MaxBins bins to cover the data
+var ErrTooManyBins = errors.New("too many bins")
+
+
+
+// Rule describes the automatic choice of the bin width
+type Rule int
+
+const (
+  FreedmanDiaconis Rule = iota  // width 2 IQR / n^(1/3)
+  Sturges                       // log2(n) + 1 bins
+)
+
+
+
+// ParseRule turns the name of a binning rule (fd or sturges) into a Rule
+func ParseRule(name string) (Rule, error) {
+  switch strings.ToLower(name) {
+  case "fd", "freedman-diaconis":
+    return FreedmanDiaconis, nil
+  case "sturges":
+    return Sturges, nil
+  }
+  return FreedmanDiaconis, fmt.Errorf("unknown binning rule %q", name)
+}
+
+
+
+// Options describes the columns to bin and the choice of the bins. At
+// most one of Bins and Width may be set, if neither is the bins are
+// chosen according to Rule.
+type Options struct {
+  Columns []parser.Column
+  Bins int            // number of bins, 0 for automatic
+  Width float64       // width of the bins, 0 for automatic
+  Rule Rule
+  Parse parser.Config
+}
+
+
+
+// Histogram describes the histogram of a single column of a file. Bin i
+// covers [Min + i*Width, Min + (i+1)*Width), the last bin includes its
+// upper edge. The density is normalised to unit area.
+type Histogram struct {
+  Name string
+  Column string
+  Min float64         // lower edge of the first bin
+  Width float64
+  Centers []float64
+  Counts []int
+  Density []float64
+  Count int           // number of binned samples
+  Ignored int         // number of NaN or infinite samples not binned
+}
+
+
+
+// validate checks the binning settings of the options
+func (o Options) validate() error {
+
+  if o.Bins < 0 || o.Bins > MaxBins {
+    return fmt.Errorf("invalid number of bins %d (valid: 1-%d)", o.Bins,
+      MaxBins)
+  } else if !(o.Width >= 0.0) || math.IsInf(o.Width, 0) {
+    return fmt.Errorf("invalid bin width %v", o.Width)
+  } else if o.Bins != 0 && o.Width != 0.0 {
+    return fmt.Errorf("only one of the number of bins and the bin " +
+      "width can be given")
+  }
+  return nil
+}
+
+
+
+// FromReader computes the histogram of each requested column of a plain
+// text column oriented data stream. The columns are read as by
+// statistic.ReadColumns. The name of the input is used for error
+// reporting and the Name of the returned histograms.
+func FromReader(r io.Reader, name string, opts Options) ([]Histogram,
+  error) {
+
+  if err := opts.validate(); err != nil {
+    return nil, err
+  }
+
+  cols, err := statistic.ReadColumns(r, name,
+    statistic.Options{Columns: opts.Columns, Parse: opts.Parse})
+  if err != nil {
+    return nil, err
+  }
+
+  hists := make([]Histogram, len(cols))
+  for i, col := range cols {
+    if hists[i], err = New(col, opts); err != nil {
+      return nil, fmt.Errorf("%s: column %s: %w", name, opts.Columns[i], err)
+    }
+    hists[i].Name = parser.Label(name)
+    hists[i].Column = opts.Columns[i].String()
+  }
+  return hists, nil
+}
+
+
+
+// New bins data according to opts. NaN and infinite values are not
+// binned but counted as Ignored. The bins span the range of the data;
+// if all values are identical a single bin centered on them is used.
+// ErrTooManyBins is returned if opts.Width requires more than MaxBins
+// bins.
+//
+// NOTE: The Freedman-Diaconis rule reorders data in place.
+func New(data []float64, opts Options) (Histogram, error) {
+
+  finite := make([]float64, 0, len(data))
+  for _, v := range data {
+    if !math.IsNaN(v) && !math.IsInf(v, 0) {
+      finite = append(finite, v)
+    }
+  }
+
+  h := Histogram{Count: len(finite), Ignored: len(data) - len(finite)}
+  if len(finite) == 0 {
+    return h, nil
+  }
+
+  min, max := finite[0], finite[0]
+  for _, v := range finite {
+    min = math.Min(min, v)
+    max = math.Max(max, v)
+  }
+
+  bins, width := binning(finite, max - min, opts)
+  if bins > MaxBins {
+    return h, ErrTooManyBins
+  }
+  h.Min, h.Width = min, width
+  if max == min {
+    h.Min = min - 0.5*width
+  }
+
+  h.Counts = make([]int, bins)
+  for _, v := range finite {
+    i := int(math.Floor((v - h.Min)/width))
+    if i >= bins {
+      i = bins - 1
+    } else if i < 0 {
+      i = 0
+    }
+    h.Counts[i]++
+  }
+
+  n := float64(len(finite))
+  h.Centers = make([]float64, bins)
+  h.Density = make([]float64, bins)
+  for i, c := range h.Counts {
+    h.Centers[i] = h.Min + (float64(i) + 0.5)*width
+    h.Density[i] = float64(c)/(n*width)
+  }
+  return h, nil
+}
+
+
+
+// binning returns the number and width of the bins covering a range of
+// the given size
+//
+// NOTE: If the Freedman-Diaconis width vanishes (more than half of the
+//       values are identical) or requires more bins than there are
+//       values (e.g., due to far outliers) the Sturges rule is used
+//       instead.
+func binning(data []float64, size float64, opts Options) (int, float64) {
+
+  if size == 0.0 {
+    if opts.Width != 0.0 {
+      return 1, opts.Width
+    }
+    return 1, 1.0
+  }
+
+  switch {
+  case opts.Bins != 0:
+    return opts.Bins, size/float64(opts.Bins)
+  case opts.Width != 0.0:
+    return num_bins(size, opts.Width), opts.Width
+  }
+
+  n := float64(len(data))
+  if opts.Rule == FreedmanDiaconis {
+    q, _ := statistic.Quantiles(data, []float64{0.25, 0.75},
+      statistic.DefaultQuantileMethod)
+    width := 2.0*(q[1] - q[0])/math.Cbrt(n)
+    if bins := num_bins(size, width); width > 0.0 && bins <= len(data) {
+      return bins, width
+    }
+  }
+
+  bins := int(math.Ceil(math.Log2(n))) + 1
+  return bins, size/float64(bins)
+}
+
+
+
+// num_bins returns the number of bins of the given width needed to cover
+// a range of the given size. Counts beyond MaxBins are capped at
+// MaxBins + 1 to avoid integer overflow.
+func num_bins(size, width float64) int {
+  bins := math.Ceil(size/width)
+  if !(bins <= MaxBins) {
+    return MaxBins + 1
+  } else if bins > 1 {
+    return int(bins)
+  }
+  return 1
+}
+
+
+
+// Histograms computes the histograms of the requested columns of each
+// file using numWorkers concurrent workers. There is one histogram per
+// file and column ordered by file as in fileNames and then by column.
+//
+// NOTE: An empty file name denotes stdin.
+//
+// NOTE: Files which can not be opened or parsed are ignored. Their errors
+//       are returned as parser.Errors alongside the histograms of all
+//       other files.
+func Histograms(fileNames []string, opts Options,
+  numWorkers int) ([]Histogram, error) {
+
+  if err := opts.validate(); err != nil {
+    return nil, err
+  }
+
+  hists, err := pool.Process(fileNames, numWorkers,
+    func(r io.Reader, name string) ([]Histogram, error) {
+      return FromReader(r, name, opts)
+    })
+
+  output := make([]Histogram, 0)
+  for _, h := range hists {
+    output = append(output, h...)
+  }
+  return output, err
+}
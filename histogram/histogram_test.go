@@ -0,0 +1,176 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package histogram bins the columns of column based text files into
+// histograms of equal width bins.
+package histogram
+
+import (
+  "math"
+  "testing"
+  "github.com/haskelladdict/lizard/parser"
+)
+
+
+// Tests for the fixed bin count, bin width and automatic binning rules
+func Test_Histogram_1(t *testing.T) {
+
+  data := []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+  result_1, _ := New(data, Options{Bins: 3})
+  if !float_equal(result_1.Width, 3.0) ||
+     !int_array_equal(result_1.Counts, []int{3, 3, 4}) ||
+     !float_array_equal(result_1.Centers, []float64{1.5, 4.5, 7.5}) ||
+     !float_array_equal(result_1.Density, []float64{0.1, 0.1, 4.0/30.0}) {
+    t.Errorf("Histogram test 1 failed - got %v", result_1)
+  }
+
+  result_2, _ := New(data, Options{Width: 2.0})
+  if !float_equal(result_2.Width, 2.0) ||
+     !int_array_equal(result_2.Counts, []int{2, 2, 2, 2, 2}) ||
+     !float_equal(result_2.Centers[4], 9.0) {
+    t.Errorf("Histogram test 2 failed - got %v", result_2)
+  }
+
+  result_3, _ := New(data, Options{Rule: Sturges})
+  if !float_equal(result_3.Width, 1.8) ||
+     !int_array_equal(result_3.Counts, []int{2, 2, 2, 2, 2}) {
+    t.Errorf("Histogram test 3 failed - got %v", result_3)
+  }
+
+  // IQR of 4.5 yields a width of 9/10^(1/3)
+  result_4, _ := New(data, Options{Rule: FreedmanDiaconis})
+  if !float_equal(result_4.Width, 9.0/math.Cbrt(10.0)) ||
+     !int_array_equal(result_4.Counts, []int{5, 4, 1}) ||
+     result_4.Count != 10 {
+    t.Errorf("Histogram test 4 failed - got %v", result_4)
+  }
+
+  // the density is normalised to unit area
+  area := 0.0
+  for _, d := range result_4.Density {
+    area += d*result_4.Width
+  }
+  if !float_equal(area, 1.0) {
+    t.Errorf("Histogram test 4 failed - density has area %v", area)
+  }
+}
+
+
+// Tests for degenerate data and invalid options
+func Test_Histogram_2(t *testing.T) {
+
+  result_5, _ := New([]float64{2, math.NaN(), 2, math.Inf(1)}, Options{})
+  if result_5.Count != 2 || result_5.Ignored != 2 ||
+     !int_array_equal(result_5.Counts, []int{2}) ||
+     !float_array_equal(result_5.Centers, []float64{2.0}) ||
+     !float_array_equal(result_5.Density, []float64{1.0}) {
+    t.Errorf("Histogram test 5 failed - got %v", result_5)
+  }
+
+  result_6, _ := New(nil, Options{Bins: 4})
+  if result_6.Count != 0 || len(result_6.Counts) != 0 {
+    t.Errorf("Histogram test 6 failed - got %v", result_6)
+  }
+
+  // far outliers fall back to the Sturges rule instead of exhausting
+  // memory whereas too narrow bins are rejected
+  data := []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 1e9}
+  result_7, err := New(data, Options{Rule: FreedmanDiaconis})
+  if err != nil || len(result_7.Counts) != 5 {
+    t.Errorf("Histogram test 7 failed - got %d bins (error %v)",
+      len(result_7.Counts), err)
+  }
+
+  result_8, err := New(data, Options{Width: 1e-9})
+  if err != ErrTooManyBins || len(result_8.Counts) != 0 {
+    t.Errorf("Histogram test 8 failed - unexpected error %v", err)
+  }
+
+  _, err = Histograms([]string{"test_files/test_data_1.txt"},
+    Options{Columns: []parser.Column{{Index: 0}}, Bins: 2, Width: 1.0,
+      Parse: parser.DefaultConfig()}, 4)
+  if err == nil {
+    t.Error("Histogram test 9 failed - conflicting options were accepted")
+  }
+}
+
+
+// Tests for processing several files and columns concurrently
+func Test_Histogram_3(t *testing.T) {
+
+  conf := parser.DefaultConfig()
+  conf.Missing = parser.MissingSkip
+  data_files := []string{"test_files/test_data_1.txt",
+    "test_files/does_not_exist.txt", "test_files/test_data_1.txt"}
+  opts := Options{Columns: []parser.Column{{Index: 0}}, Bins: 2,
+    Parse: conf}
+  result_10, err := Histograms(data_files, opts, 4)
+  if errs, ok := err.(parser.Errors); !ok || len(errs) != 1 {
+    t.Errorf("Histogram test 10 failed - unexpected error %v", err)
+  }
+  if len(result_10) != 2 || result_10[0].Name != data_files[0] ||
+     result_10[1].Name != data_files[2] ||
+     !int_array_equal(result_10[0].Counts, []int{5, 5}) ||
+     !int_array_equal(result_10[1].Counts, []int{5, 5}) {
+    t.Errorf("Histogram test 10 failed - got %v", result_10)
+  }
+
+  // rows with missing values are skipped for all columns
+  opts.Columns = []parser.Column{{Name: "y"}, {Name: "x"}}
+  opts.Parse.HeaderLines = 1
+  result_11, err := Histograms([]string{"test_files/test_data_2.txt"},
+    opts, 4)
+  if err != nil || len(result_11) != 2 || result_11[0].Column != "y" ||
+     !float_array_equal(result_11[0].Centers, []float64{1.5, 2.5}) ||
+     !int_array_equal(result_11[1].Counts, []int{2, 1}) {
+    t.Errorf("Histogram test 11 failed - got %v (error %v)", result_11, err)
+  }
+}
+
+
+// Support Functions
+//
+// int_array_equal compares two arrays of ints for equality
+func int_array_equal(a1, a2 []int) bool {
+  if len(a1) != len(a2) {
+    return false
+  }
+
+  for i, v := range a1 {
+    if v != a2[i] {
+      return false
+    }
+  }
+  return true
+}
+
+
+
+// float_array_equal compares two arrays of floats for equality using
+// float_equal
+func float_array_equal(a1, a2 []float64) bool {
+  if len(a1) != len(a2) {
+    return false
+  }
+
+  for i, v := range a1 {
+    if !float_equal(v, a2[i]) {
+      return false
+    }
+  }
+  return true
+}
+
+
+
+// float_equal compares two float numbers for equality
+// NOTE: the floating point comparison is based on an epsilon
+//       which was chosen empirically so its not rigorous
+func float_equal(a1, a2 float64) bool {
+  epsilon := 1e-13
+  if math.Abs(a2-a1) > epsilon * math.Abs(a1) {
+    return false
+  }
+  return true
+}
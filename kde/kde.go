@@ -0,0 +1,322 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package kde computes kernel density estimates of the columns of column
+// based text files on an evenly spaced grid. Gaussian and Epanechnikov
+// kernels are supported, the bandwidth is either given or chosen via
+// Silverman's or Scott's rule of thumb.
+//
+// The estimate of a single input stream is computed via FromReader,
+// Estimates processes a list of files concurrently.
+//
+package kde
+
+import (
+  "fmt"
+  "io"
+  "math"
+  "strings"
+  "github.com/haskelladdict/lizard/parser"
+  "github.com/haskelladdict/lizard/pool"
+  "github.com/haskelladdict/lizard/statistic"
+)
+
+
+
+// DefaultPoints is the number of grid points used unless requested
+// otherwise
+const DefaultPoints = 512
+
+
+
+// cut is the distance in bandwidths by which the default grid extends
+// beyond the range of the data
+const cut = 3.0
+
+
+
+// Kernel describes the smoothing kernel. All kernels are scaled to unit
+// variance so the bandwidth is the standard deviation of the kernel.
+type Kernel int
+
+const (
+  Gaussian Kernel = iota
+  Epanechnikov
+)
+
+
+
+// ParseKernel turns the name of a kernel (gaussian or epanechnikov) into
+// a Kernel
+func ParseKernel(name string) (Kernel, error) {
+  switch strings.ToLower(name) {
+  case "gaussian", "normal":
+    return Gaussian, nil
+  case "epanechnikov":
+    return Epanechnikov, nil
+  }
+  return Gaussian, fmt.Errorf("unknown kernel %q", name)
+}
+
+
+
+// Rule describes the automatic choice of the bandwidth
+type Rule int
+
+const (
+  Silverman Rule = iota  // 0.9 min(std, IQR/1.34) / n^(1/5)
+  Scott                  // 1.06 std / n^(1/5)
+)
+
+
+
+// ParseRule turns the name of a bandwidth rule (silverman or scott) into
+// a Rule
+func ParseRule(name string) (Rule, error) {
+  switch strings.ToLower(name) {
+  case "silverman":
+    return Silverman, nil
+  case "scott":
+    return Scott, nil
+  }
+  return Silverman, fmt.Errorf("unknown bandwidth rule %q", name)
+}
+
+
+
+// Options describes the columns to estimate, the kernel and the
+// evaluation grid. Unless Grid is set the grid extends three bandwidths
+// beyond the range of the data.
+type Options struct {
+  Columns []parser.Column
+  Kernel Kernel
+  Bandwidth float64   // bandwidth, 0 for automatic
+  Rule Rule
+  Points int          // number of grid points, 0 for the default
+  Grid bool           // evaluate on [GridMin, GridMax]
+  GridMin, GridMax float64
+  Parse parser.Config
+}
+
+
+
+// Estimate describes the density estimate of a single column of a file
+// evaluated at the points X
+type Estimate struct {
+  Name string
+  Column string
+  Bandwidth float64
+  X []float64
+  Density []float64
+  Count int           // number of samples
+  Ignored int         // number of NaN or infinite samples not used
+}
+
+
+
+// validate checks the bandwidth and grid settings of the options
+func (o Options) validate() error {
+
+  if !(o.Bandwidth >= 0.0) || math.IsInf(o.Bandwidth, 0) {
+    return fmt.Errorf("invalid bandwidth %v", o.Bandwidth)
+  } else if o.Points < 0 || o.Points == 1 {
+    return fmt.Errorf("invalid number of grid points %d", o.Points)
+  } else if o.Grid && !(o.GridMin < o.GridMax) {
+    return fmt.Errorf("invalid grid [%v, %v]", o.GridMin, o.GridMax)
+  }
+  return nil
+}
+
+
+
+// FromReader computes the density estimate of each requested column of a
+// plain text column oriented data stream. The columns are read as by
+// statistic.ReadColumns. The name of the input is used for error
+// reporting and the Name of the returned estimates.
+func FromReader(r io.Reader, name string, opts Options) ([]Estimate,
+  error) {
+
+  if err := opts.validate(); err != nil {
+    return nil, err
+  }
+
+  cols, err := statistic.ReadColumns(r, name,
+    statistic.Options{Columns: opts.Columns, Parse: opts.Parse})
+  if err != nil {
+    return nil, err
+  }
+
+  estimates := make([]Estimate, len(cols))
+  for i, col := range cols {
+    estimates[i] = New(col, opts)
+    estimates[i].Name = parser.Label(name)
+    estimates[i].Column = opts.Columns[i].String()
+  }
+  return estimates, nil
+}
+
+
+
+// New estimates the density of data on the grid described by opts. NaN
+// and infinite values are not used but counted as Ignored.
+//
+// NOTE: The estimate is evaluated directly at each grid point which is
+//       O(n m) for n samples and m grid points.
+//
+// NOTE: Silverman's rule reorders data in place.
+func New(data []float64, opts Options) Estimate {
+
+  finite := make([]float64, 0, len(data))
+  for _, v := range data {
+    if !math.IsNaN(v) && !math.IsInf(v, 0) {
+      finite = append(finite, v)
+    }
+  }
+
+  e := Estimate{Count: len(finite), Ignored: len(data) - len(finite)}
+  if len(finite) == 0 {
+    return e
+  }
+
+  e.Bandwidth = opts.Bandwidth
+  if e.Bandwidth == 0.0 {
+    e.Bandwidth = Bandwidth(finite, opts.Rule)
+  }
+  e.X = grid(finite, e.Bandwidth, opts)
+
+  kernel := gaussian
+  if opts.Kernel == Epanechnikov {
+    kernel = epanechnikov
+  }
+
+  n, h := float64(len(finite)), e.Bandwidth
+  e.Density = make([]float64, len(e.X))
+  for i, x := range e.X {
+    sum := 0.0
+    for _, v := range finite {
+      sum += kernel((x - v)/h)
+    }
+    e.Density[i] = sum/(n*h)
+  }
+  return e
+}
+
+
+
+// Bandwidth returns the bandwidth of a Gaussian kernel estimate of data
+// according to rule. If the data has no spread the bandwidth of data with
+// unit standard deviation is returned.
+//
+// NOTE: data must not contain NaN values and is reordered in place.
+func Bandwidth(data []float64, rule Rule) float64 {
+
+  n := float64(len(data))
+  mean := 0.0
+  for _, v := range data {
+    mean += v
+  }
+  mean /= n
+
+  variance := 0.0
+  for _, v := range data {
+    variance += (v - mean)*(v - mean)
+  }
+  spread := 0.0
+  if len(data) > 1 {
+    spread = math.Sqrt(variance/(n - 1))
+  }
+
+  factor := 1.06
+  if rule == Silverman {
+    factor = 0.9
+    q, _ := statistic.Quantiles(data, []float64{0.25, 0.75},
+      statistic.DefaultQuantileMethod)
+    if iqr := (q[1] - q[0])/1.34; iqr > 0.0 && iqr < spread {
+      spread = iqr
+    }
+  }
+
+  if spread == 0.0 {
+    spread = 1.0
+  }
+  return factor*spread*math.Pow(n, -0.2)
+}
+
+
+
+// grid returns the points at which the estimate is evaluated
+func grid(data []float64, h float64, opts Options) []float64 {
+
+  points := opts.Points
+  if points == 0 {
+    points = DefaultPoints
+  }
+
+  lo, hi := opts.GridMin, opts.GridMax
+  if !opts.Grid {
+    lo, hi = data[0], data[0]
+    for _, v := range data {
+      lo = math.Min(lo, v)
+      hi = math.Max(hi, v)
+    }
+    lo, hi = lo - cut*h, hi + cut*h
+  }
+
+  x := make([]float64, points)
+  step := (hi - lo)/float64(points - 1)
+  for i := range x {
+    x[i] = lo + float64(i)*step
+  }
+  x[points-1] = hi
+  return x
+}
+
+
+
+// gaussian is the standard normal density
+func gaussian(u float64) float64 {
+  return math.Exp(-0.5*u*u)/math.Sqrt(2.0*math.Pi)
+}
+
+
+
+// epanechnikov is the Epanechnikov kernel scaled to unit variance, i.e.,
+// with support [-sqrt(5), sqrt(5)]
+func epanechnikov(u float64) float64 {
+  if u*u >= 5.0 {
+    return 0.0
+  }
+  return 0.75*(1.0 - u*u/5.0)/math.Sqrt(5.0)
+}
+
+
+
+// Estimates computes the density estimates of the requested columns of
+// each file using numWorkers concurrent workers. There is one estimate
+// per file and column ordered by file as in fileNames and then by
+// column.
+//
+// NOTE: An empty file name denotes stdin.
+//
+// NOTE: Files which can not be opened or parsed are ignored. Their errors
+//       are returned as parser.Errors alongside the estimates of all
+//       other files.
+func Estimates(fileNames []string, opts Options,
+  numWorkers int) ([]Estimate, error) {
+
+  if err := opts.validate(); err != nil {
+    return nil, err
+  }
+
+  estimates, err := pool.Process(fileNames, numWorkers,
+    func(r io.Reader, name string) ([]Estimate, error) {
+      return FromReader(r, name, opts)
+    })
+
+  output := make([]Estimate, 0)
+  for _, e := range estimates {
+    output = append(output, e...)
+  }
+  return output, err
+}
@@ -0,0 +1,139 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package kde computes kernel density estimates of the columns of column
+// based text files.
+package kde
+
+import (
+  "math"
+  "testing"
+  "github.com/haskelladdict/lizard/parser"
+)
+
+
+// Tests for the bandwidth rules
+func Test_KDE_1(t *testing.T) {
+
+  data := []float64{9, 1, 8, 2, 7, 3, 6, 4, 5, 0}
+  std := math.Sqrt(55.0/6.0)
+  result_1 := Bandwidth(data, Silverman)
+  if !float_equal(result_1, 0.9*std*math.Pow(10.0, -0.2)) {
+    t.Errorf("KDE test 1 failed - got bandwidth %v", result_1)
+  }
+
+  result_2 := Bandwidth(data, Scott)
+  if !float_equal(result_2, 1.06*std*math.Pow(10.0, -0.2)) {
+    t.Errorf("KDE test 2 failed - got bandwidth %v", result_2)
+  }
+
+  // the interquartile range limits the bandwidth of heavy tailed data
+  data = []float64{-100, 0, 0, 1, 1, 2, 2, 100}
+  result_3 := Bandwidth(data, Silverman)
+  if !float_equal(result_3, 0.9*(2.0/1.34)*math.Pow(8.0, -0.2)) {
+    t.Errorf("KDE test 3 failed - got bandwidth %v", result_3)
+  }
+}
+
+
+// Tests for evaluating the kernels on a grid
+func Test_KDE_2(t *testing.T) {
+
+  opts := Options{Bandwidth: 1.0, Points: 3, Grid: true, GridMin: -1.0,
+    GridMax: 1.0}
+  result_4 := New([]float64{0.0, math.NaN()}, opts)
+  phi_0 := 1.0/math.Sqrt(2.0*math.Pi)
+  phi_1 := math.Exp(-0.5)*phi_0
+  if result_4.Count != 1 || result_4.Ignored != 1 ||
+     !float_array_equal(result_4.X, []float64{-1.0, 0.0, 1.0}) ||
+     !float_array_equal(result_4.Density, []float64{phi_1, phi_0, phi_1}) {
+    t.Errorf("KDE test 4 failed - got %v", result_4)
+  }
+
+  opts.Kernel = Epanechnikov
+  result_5 := New([]float64{0.0}, opts)
+  k_0, k_1 := 0.75/math.Sqrt(5.0), 0.6/math.Sqrt(5.0)
+  if !float_array_equal(result_5.Density, []float64{k_1, k_0, k_1}) {
+    t.Errorf("KDE test 5 failed - got %v", result_5)
+  }
+
+  // both kernels yield a density of unit area on the default grid
+  data := []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+  for _, kernel := range []Kernel{Gaussian, Epanechnikov} {
+    result_6 := New(data, Options{Kernel: kernel})
+    area := 0.0
+    for i := 1; i < len(result_6.X); i++ {
+      area += 0.5*(result_6.Density[i] + result_6.Density[i-1])*
+        (result_6.X[i] - result_6.X[i-1])
+    }
+    if len(result_6.X) != DefaultPoints || math.Abs(area - 1.0) > 1e-3 {
+      t.Errorf("KDE test 6 failed - kernel %d has area %v", kernel, area)
+    }
+  }
+}
+
+
+// Tests for processing several files concurrently
+func Test_KDE_3(t *testing.T) {
+
+  data_files := []string{"test_files/test_data_1.txt",
+    "test_files/does_not_exist.txt"}
+  opts := Options{Columns: []parser.Column{{Index: 0}}, Rule: Scott,
+    Points: 10, Parse: parser.DefaultConfig()}
+  result_7, err := Estimates(data_files, opts, 4)
+  if errs, ok := err.(parser.Errors); !ok || len(errs) != 1 {
+    t.Errorf("KDE test 7 failed - unexpected error %v", err)
+  }
+
+  h := 1.06*math.Sqrt(55.0/6.0)*math.Pow(10.0, -0.2)
+  if len(result_7) != 1 || result_7[0].Name != data_files[0] ||
+     result_7[0].Column != "0" || len(result_7[0].X) != 10 ||
+     !float_equal(result_7[0].Bandwidth, h) ||
+     !float_equal(result_7[0].X[0], -3.0*h) ||
+     !float_equal(result_7[0].X[9], 9.0 + 3.0*h) {
+    t.Errorf("KDE test 7 failed - got %v", result_7)
+  }
+
+  opts.Points = 1
+  if _, err := Estimates(data_files, opts, 4); err == nil {
+    t.Error("KDE test 8 failed - invalid grid was accepted")
+  }
+
+  opts.Points = 0
+  opts.Grid, opts.GridMin, opts.GridMax = true, 2.0, 2.0
+  if _, err := Estimates(data_files, opts, 4); err == nil {
+    t.Error("KDE test 9 failed - empty grid was accepted")
+  }
+}
+
+
+// Support Functions
+//
+// float_array_equal compares two arrays of floats for equality using
+// float_equal
+func float_array_equal(a1, a2 []float64) bool {
+  if len(a1) != len(a2) {
+    return false
+  }
+
+  for i, v := range a1 {
+    if !float_equal(v, a2[i]) {
+      return false
+    }
+  }
+  return true
+}
+
+
+
+// float_equal compares two float numbers for equality
+// NOTE: the floating point comparison is based on an epsilon
+//       which was chosen empirically so its not rigorous
+func float_equal(a1, a2 float64) bool {
+  epsilon := 1e-13
+  if math.Abs(a2-a1) > epsilon * math.Abs(a1) {
+    return false
+  }
+  return true
+}
@@ -2,86 +2,359 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 //
-// lizard provides functionality for analyzing column based numerical data
+// lizard provides functionality for analyzing column based numerical data.
+// Each analysis is a separate command with its own options, e.g.,
+//
+//   lizard stats -c 1 -m data.txt
+//   lizard average -c 1 -x 0 run*.dat
+//   lizard hist -c 1 -bins 50 data.txt
+//   lizard kde -c 1 -kernel epanechnikov data.txt
+//   lizard block -c 1 energy.dat
+//   lizard acf -c 1 -lags 100 energy.dat
+//
+// Use "lizard help <command>" for the options of a command.
 package main
 
 import (
-  "fmt"
   "flag"
-  "math"
+  "fmt"
+  "log"
+  "os"
   "runtime"
+  "strconv"
   "strings"
-  "github.com/haskelladdict/lizard/average"
-  "github.com/haskelladdict/lizard/statistic"
+  "github.com/haskelladdict/lizard/output"
+  "github.com/haskelladdict/lizard/parser"
 )
 
 
 
-// define variable used in command line parsing
-var averageFiles bool
-var columnID int         // id of column to act on, 0 = leftmost columns
-var fileStatistic bool
-var numWorkers int
-var numThreads int
-var wantMedian bool      // also compute median when computing statistic via -s
-                         // NOTE: median is O(n) on average and requires
-                         // memory the size of the data array
+// command describes a single lizard command
+type command struct {
+  name string
+  summary string      // one line description shown in the command list
+  run func(args []string)
+}
 
 
-func init() {
-  flag.BoolVar(&averageFiles, "a", false, "average columns")
-  flag.BoolVar(&fileStatistic, "s", false, "compute file statistics")
-  flag.IntVar(&columnID, "c", 0, "column id (default : 0)")
-  flag.BoolVar(&wantMedian, "m", false, "compute median with -s (default: false)")
-  flag.IntVar(&numWorkers, "w", 4, "number of worker goroutines (default: 4)")
-  flag.IntVar(&numThreads, "t", runtime.NumCPU(),
-    "maximum number of threads (default: number of CPUs")
+
+// commands lists all available commands
+var commands = []command{
+  {"stats", "compute the statistics of columns of each file", run_stats},
+  {"average", "average columns row by row across files", run_average},
+  {"hist", "compute histograms of columns of each file", run_histogram},
+  {"kde", "compute kernel density estimates of columns of each file",
+    run_kde},
+  {"block", "estimate errors of correlated time series by block averaging",
+    run_blocking},
+  {"acf", "compute autocorrelation functions and times of time series",
+    run_autocorr},
+}
+
+
+
+// common_flags are the flags shared by all commands
+type common_flags struct {
+  columnIDs string     // ids, ranges or header names of columns to act
+                       // on, 0 = leftmost columns
+  comments string      // comma separated list of comment line prefixes
+  skipBlank bool       // ignore blank lines
+  headerLines int      // number of header lines to skip
+  delimiter string     // field delimiter, empty for whitespace
+  missingPolicy string // handling of missing values
+  missingTokens string // comma separated list of missing value tokens
+  fillValue float64    // substitute for missing values
+  strict bool          // abort if any input file fails to parse
+  numWorkers int
+  numThreads int
+  outputFormat string  // text, json, csv, tsv or table
+  floatFormat string   // printf style float format or full
+}
+
+
+
+// settings contains the parsed common flags and the input files
+type settings struct {
+  files []string      // input files, "" denotes stdin
+  columns []parser.Column
+  conf parser.Config
+  numWorkers int
+  strict bool
+  format output.Format
+  floats output.FloatFormat
 }
 
 
 
 func main() {
 
-  // parse command line flags 
-  flag.Parse()
+  args := os.Args[1:]
+  if len(args) != 0 && strings.HasPrefix(args[0], "-") &&
+     !is_help(args[0]) {
+    var err error
+    if args, err = legacy_args(args); err != nil {
+      log.Fatal(err)
+    }
+  }
 
-  // set the number of threads for go runtime
-  runtime.GOMAXPROCS(numThreads)
+  if len(args) == 0 || is_help(args[0]) {
+    usage()
+    return
+  }
 
-  // if there are no input files we assume stdin
-  inputFiles := flag.Args()
-  if len(inputFiles) == 0 {
-    numWorkers = 1
-  } else if len(inputFiles) < numWorkers {
-    numWorkers = len(inputFiles)
+  name, args := args[0], args[1:]
+  if name == "help" {
+    if len(args) == 0 {
+      usage()
+      return
+    }
+    name, args = args[0], []string{"-help"}
   }
 
-  if averageFiles {
-    avg := average.Average(inputFiles, columnID, numWorkers)
-    for _, v := range avg {
-      fmt.Printf("%8.4f\n", v)
+  for _, cmd := range commands {
+    if cmd.name == name {
+      cmd.run(args)
+      return
     }
   }
 
-  if fileStatistic {
-    // if no input files are provided we assume the user meant stdin
-    // which we signal with an empty string
-    if len(inputFiles) == 0 {
-      inputFiles = append(inputFiles, "")
+  fmt.Fprintf(os.Stderr, "lizard: unknown command %q\n\n", name)
+  usage()
+  os.Exit(2)
+}
+
+
+
+// usage prints the list of available commands
+func usage() {
+  fmt.Fprintf(os.Stderr, "usage: lizard <command> [options] [files]\n\n")
+  fmt.Fprintf(os.Stderr, "commands:\n")
+  for _, cmd := range commands {
+    fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+  }
+  fmt.Fprintf(os.Stderr, "\nUse \"lizard help <command>\" or " +
+    "\"lizard <command> --help\" for the options of a command. Without " +
+    "input files lizard reads from stdin.\n")
+}
+
+
+
+// is_help returns true if arg requests help
+func is_help(arg string) bool {
+  return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+
+
+// legacy_args translates an invocation via the former mode flags -a and
+// -s into the corresponding command
+func legacy_args(args []string) ([]string, error) {
+
+  var name string
+  var rest []string
+  for i, arg := range args {
+    if arg == "--" {
+      rest = append(rest, args[i:]...)
+      break
+    }
+
+    var mode string
+    switch arg {
+    case "-a", "--a":
+      mode = "average"
+    case "-s", "--s":
+      mode = "stats"
+    default:
+      rest = append(rest, arg)
+      continue
     }
 
-    stats := statistic.Statistic(inputFiles, columnID, wantMedian, numWorkers)
-    for _, stat := range stats {
-      if wantMedian {
-        fmt.Printf("%s : %8.8f +/- %8.8f  (mean +/- std)\n%s   %8.8f (median) \n",
-          stat.Name, stat.Mean, math.Sqrt(stat.Variance), 
-          strings.Repeat(" ", len(stat.Name)), stat.Median)
-      } else {
-        fmt.Printf("%s : %8.8f +/- %8.8f  (mean +/- std)\n", stat.Name,
-          stat.Mean, math.Sqrt(stat.Variance))
-      }
+    if name != "" && name != mode {
+      return nil, fmt.Errorf("-a and -s can not be combined, use " +
+        "\"lizard average\" or \"lizard stats\"")
     }
+    name = mode
   }
+
+  if name == "" {
+    return nil, fmt.Errorf("missing command, see \"lizard help\"")
+  }
+  return append([]string{name}, rest...), nil
+}
+
+
+
+// new_flag_set returns the flag set of a command including the common
+// flags
+func new_flag_set(name, description string) (*flag.FlagSet,
+  *common_flags) {
+
+  fs := flag.NewFlagSet(name, flag.ExitOnError)
+  fs.Usage = func() {
+    fmt.Fprintf(fs.Output(), "usage: lizard %s [options] [files]\n\n%s\n\n" +
+      "options:\n", name, description)
+    fs.PrintDefaults()
+  }
+
+  c := &common_flags{}
+  fs.StringVar(&c.columnIDs, "c", "0",
+    "comma separated list of column ids, ranges (e.g. 5-9) or column " +
+    "names in header line (default : 0)")
+  fs.StringVar(&c.comments, "comment", "#",
+    "comma separated list of comment line prefixes (default: #)")
+  fs.BoolVar(&c.skipBlank, "skipblank", true,
+    "ignore blank lines (default: true)")
+  fs.IntVar(&c.headerLines, "header", 0,
+    "number of header lines preceding the data (default: 0)")
+  fs.StringVar(&c.delimiter, "d", "",
+    "field delimiter, e.g. comma, tab, semicolon or any string " +
+    "(default: whitespace)")
+  fs.StringVar(&c.missingPolicy, "missing", "error",
+    "handling of missing values: error, skip, nan or fill (default: error)")
+  fs.StringVar(&c.missingTokens, "na",
+    strings.Join(parser.DefaultMissing, ","),
+    "comma separated list of fields denoting missing values " +
+    "(default: NA,N/A,-,<empty field> and NaN unless -missing error)")
+  fs.Float64Var(&c.fillValue, "fill", 0.0,
+    "substitute for missing values with -missing fill (default: 0)")
+  fs.BoolVar(&c.strict, "strict", false,
+    "abort if any input file can not be parsed instead of ignoring it " +
+    "(default: false)")
+  fs.IntVar(&c.numWorkers, "w", 4,
+    "number of worker goroutines (default: 4)")
+  fs.IntVar(&c.numThreads, "t", runtime.NumCPU(),
+    "maximum number of threads (default: number of CPUs")
+  fs.StringVar(&c.outputFormat, "o", "text",
+    "output format: text, json (one object per line), csv, tsv or " +
+    "table")
+  fs.StringVar(&c.floatFormat, "fmt", "",
+    "float format %f, %e or %g with optional width and precision (e.g. " +
+    "%.12e) or full for round-trip precision (default: full for " +
+    "machine readable output)")
+  return fs, c
 }
 
 
+
+// settings parses the command line of a command and turns the common
+// flags into settings. The float format defaults to text_default for
+// text output.
+func (c *common_flags) settings(fs *flag.FlagSet, args []string,
+  text_default string) settings {
+
+  fs.Parse(args)
+
+  // set the number of threads for go runtime
+  runtime.GOMAXPROCS(c.numThreads)
+
+  // if there are no input files we assume stdin which we signal with an
+  // empty string
+  s := settings{files: fs.Args(), numWorkers: c.numWorkers,
+    strict: c.strict}
+  if len(s.files) == 0 {
+    s.numWorkers = 1
+    s.files = append(s.files, "")
+  }
+
+  var err error
+  if s.format, err = output.ParseFormat(c.outputFormat); err != nil {
+    log.Fatal(err)
+  }
+
+  spec := c.floatFormat
+  if spec == "" && s.format == output.Text {
+    spec = text_default
+  }
+  if s.floats, err = output.ParseFloatFormat(spec); err != nil {
+    log.Fatal(err)
+  }
+
+  policy, err := parser.ParseMissingPolicy(c.missingPolicy)
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  s.conf = parser.Config{
+    Comments: strings.Split(c.comments, ","),
+    SkipBlank: c.skipBlank,
+    HeaderLines: c.headerLines,
+    Delimiter: parser.ParseDelimiter(c.delimiter),
+    Missing: policy,
+    Fill: c.fillValue,
+  }
+  if is_set(fs, "na") {
+    s.conf.MissingTokens = strings.Split(c.missingTokens, ",")
+  }
+
+  if s.columns, err = parser.ParseColumns(c.columnIDs); err != nil {
+    log.Fatal(err)
+  }
+  return s
+}
+
+
+
+// optional_column parses a column specification which may be empty
+func optional_column(spec string) *parser.Column {
+  if spec == "" {
+    return nil
+  }
+  c := parser.ParseColumn(spec)
+  return &c
+}
+
+
+
+// is_set returns true if the flag name was given on the command line
+func is_set(fs *flag.FlagSet, name string) bool {
+  set := false
+  fs.Visit(func(f *flag.Flag) {
+    if f.Name == name {
+      set = true
+    }
+  })
+  return set
+}
+
+
+
+// report_errors prints a warning for each input file which failed to
+// parse. In strict mode lizard bails out instead.
+func report_errors(err error, strict bool) {
+
+  if err == nil {
+    return
+  }
+
+  if strict {
+    log.Fatalf("Error: %v", err)
+  }
+
+  if errs, ok := err.(parser.Errors); ok {
+    for _, e := range errs {
+      log.Printf("Warning: %v. Ignoring file.\n", e)
+    }
+  } else {
+    log.Printf("Warning: %v\n", err)
+  }
+}
+
+
+
+// parse_floats parses a comma separated list of floating point numbers
+func parse_floats(list string) ([]float64, error) {
+
+  if list == "" {
+    return nil, nil
+  }
+
+  var values []float64
+  for _, item := range strings.Split(list, ",") {
+    v, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+    if err != nil {
+      return nil, err
+    }
+    values = append(values, v)
+  }
+  return values, nil
+}
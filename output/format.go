@@ -0,0 +1,69 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package output
+
+import (
+  "fmt"
+  "regexp"
+  "strconv"
+)
+
+
+
+// float_spec matches printf style float verbs with optional width and
+// precision such as %g, %.6e or %12.4f
+var float_spec = regexp.MustCompile(`^%-?[0-9]*(\.[0-9]+)?[eEfFgG]$`)
+
+
+
+// FloatFormat describes how floats are turned into text. The zero value
+// writes the shortest representation which reads back to the same
+// float64 (full round-trip precision).
+type FloatFormat struct {
+  spec string
+}
+
+
+
+// Full is the FloatFormat with full round-trip precision
+var Full = FloatFormat{}
+
+
+
+// ParseFloatFormat turns a float format specification into a FloatFormat.
+// Valid specifications are printf style verbs %f, %e and %g (or %E, %G)
+// with optional width and precision, e.g., %.12e, or "full" for the
+// shortest representation with full round-trip precision.
+func ParseFloatFormat(spec string) (FloatFormat, error) {
+  if spec == "full" || spec == "" {
+    return Full, nil
+  }
+
+  if !float_spec.MatchString(spec) {
+    return Full, fmt.Errorf("invalid float format %q (expected e.g. %%g, " +
+      "%%.6e, %%12.4f or full)", spec)
+  }
+  return FloatFormat{spec}, nil
+}
+
+
+
+// Format turns f into text
+func (f FloatFormat) Format(v float64) string {
+  if f.spec == "" {
+    return strconv.FormatFloat(v, 'g', -1, 64)
+  }
+  return fmt.Sprintf(f.spec, v)
+}
+
+
+
+// String returns the specification of the format
+func (f FloatFormat) String() string {
+  if f.spec == "" {
+    return "full"
+  }
+  return f.spec
+}
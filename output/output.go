@@ -0,0 +1,234 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package output writes analysis results as records of named fields in
+// machine readable formats: JSON (one object per line), CSV or TSV with a
+// header line or a table with aligned columns.
+//
+package output
+
+import (
+  "bytes"
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "math"
+  "strings"
+  "text/tabwriter"
+)
+
+
+
+// Format describes the layout of the written records
+type Format int
+
+const (
+  Text Format = iota   // human readable layout chosen by the caller
+  JSON                 // one JSON object per record and line
+  CSV                  // comma separated values preceded by a header
+  TSV                  // tab separated values preceded by a header
+  Table                // columns aligned with spaces preceded by a header
+)
+
+
+
+// ParseFormat turns the name of an output format (text, json, csv, tsv or
+// table) into a Format
+func ParseFormat(name string) (Format, error) {
+  switch strings.ToLower(name) {
+  case "text":
+    return Text, nil
+  case "json":
+    return JSON, nil
+  case "csv":
+    return CSV, nil
+  case "tsv":
+    return TSV, nil
+  case "table":
+    return Table, nil
+  }
+  return Text, fmt.Errorf("unknown output format %q", name)
+}
+
+
+
+// Writer writes records consisting of the same fields in one of the
+// machine readable formats. Values may be strings, ints or float64.
+//
+// NOTE: Floats are written with the shortest representation which reads
+//       back to the same float64 unless a different FloatFormat is set.
+//       NaN and infinities are written as null in JSON.
+type Writer struct {
+  format Format
+  fields []string
+  floats FloatFormat
+  out io.Writer
+  csv *csv.Writer
+  table *tabwriter.Writer
+  header bool         // true once the header was written
+}
+
+
+
+// NewWriter returns a Writer writing records with the given fields to w.
+// The Text format is written like Table.
+func NewWriter(w io.Writer, format Format, fields []string) *Writer {
+
+  writer := &Writer{format: format, fields: fields, out: w}
+  switch format {
+  case CSV:
+    writer.csv = csv.NewWriter(w)
+  case TSV:
+    writer.csv = csv.NewWriter(w)
+    writer.csv.Comma = '\t'
+  case Text, Table:
+    writer.table = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+  }
+  return writer
+}
+
+
+
+// Write writes a single record. The values are expected in the order of
+// the fields.
+func (w *Writer) Write(values ...interface{}) error {
+
+  if len(values) != len(w.fields) {
+    return fmt.Errorf("record has %d values but %d fields", len(values),
+      len(w.fields))
+  }
+
+  switch w.format {
+  case JSON:
+    return w.write_json(values)
+  case CSV, TSV:
+    if !w.header {
+      w.header = true
+      if err := w.csv.Write(w.fields); err != nil {
+        return err
+      }
+    }
+    return w.csv.Write(w.format_values(values))
+  default:
+    if !w.header {
+      w.header = true
+      if err := write_row(w.table, w.fields); err != nil {
+        return err
+      }
+    }
+    return write_row(w.table, w.format_values(values))
+  }
+}
+
+
+
+// SetFloatFormat sets the format of all floats written subsequently.
+// Padding requested by the format is removed since the output formats
+// take care of the alignment.
+func (w *Writer) SetFloatFormat(f FloatFormat) {
+  w.floats = f
+}
+
+
+
+// Flush writes any buffered records to the underlying writer
+func (w *Writer) Flush() error {
+  switch {
+  case w.csv != nil:
+    w.csv.Flush()
+    return w.csv.Error()
+  case w.table != nil:
+    return w.table.Flush()
+  }
+  return nil
+}
+
+
+
+// write_json writes the record as a single JSON object with the keys in
+// the order of the fields
+func (w *Writer) write_json(values []interface{}) error {
+
+  var b strings.Builder
+  b.WriteString("{")
+  for i, v := range values {
+    if i != 0 {
+      b.WriteString(", ")
+    }
+    key, err := marshal(w.fields[i])
+    if err != nil {
+      return err
+    }
+    b.Write(key)
+    b.WriteString(": ")
+
+    if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+      b.WriteString("null")
+      continue
+    } else if ok {
+      b.WriteString(w.format_float(f))
+      continue
+    }
+
+    val, err := marshal(v)
+    if err != nil {
+      return err
+    }
+    b.Write(val)
+  }
+  b.WriteString("}\n")
+
+  _, err := io.WriteString(w.out, b.String())
+  return err
+}
+
+
+
+// marshal returns the JSON encoding of v without escaping HTML
+// characters, e.g., of the name <stdin>
+func marshal(v interface{}) ([]byte, error) {
+  var b bytes.Buffer
+  enc := json.NewEncoder(&b)
+  enc.SetEscapeHTML(false)
+  if err := enc.Encode(v); err != nil {
+    return nil, err
+  }
+  return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+
+
+// write_row writes a tab separated row to a tabwriter. Each cell is
+// terminated by a tab so the last column is aligned as well.
+func write_row(w io.Writer, cells []string) error {
+  _, err := io.WriteString(w, strings.Join(cells, "\t") + "\t\n")
+  return err
+}
+
+
+
+// format_values turns a list of values into strings
+func (w *Writer) format_values(values []interface{}) []string {
+
+  cells := make([]string, len(values))
+  for i, v := range values {
+    switch val := v.(type) {
+    case float64:
+      cells[i] = w.format_float(val)
+    case string:
+      cells[i] = val
+    default:
+      cells[i] = fmt.Sprint(val)
+    }
+  }
+  return cells
+}
+
+
+
+// format_float formats f according to the float format of the writer
+func (w *Writer) format_float(f float64) string {
+  return strings.TrimSpace(w.floats.Format(f))
+}
@@ -0,0 +1,114 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package output writes analysis results as records of named fields.
+package output
+
+import (
+  "math"
+  "strings"
+  "testing"
+)
+
+
+// Tests for the machine readable output formats
+func Test_Writer_1(t *testing.T) {
+
+  fields := []string{"file", "count", "mean"}
+  records := [][]interface{}{{"a.dat", 3, 0.1}, {"b, c", 0, math.NaN()},
+    {"<stdin>", 1, 2.0}}
+
+  expected := map[Format]string{
+    JSON: "{\"file\": \"a.dat\", \"count\": 3, \"mean\": 0.1}\n" +
+      "{\"file\": \"b, c\", \"count\": 0, \"mean\": null}\n" +
+      "{\"file\": \"<stdin>\", \"count\": 1, \"mean\": 2}\n",
+    CSV: "file,count,mean\na.dat,3,0.1\n\"b, c\",0,NaN\n<stdin>,1,2\n",
+    TSV: "file\tcount\tmean\na.dat\t3\t0.1\nb, c\t0\tNaN\n" +
+      "<stdin>\t1\t2\n",
+    Table: "     file  count  mean\n    a.dat      3   0.1\n     b, c      0   " +
+      "NaN\n  <stdin>      1     2\n",
+  }
+
+  for format, exp := range expected {
+    var b strings.Builder
+    w := NewWriter(&b, format, fields)
+    for _, r := range records {
+      if err := w.Write(r...); err != nil {
+        t.Fatalf("output test 1 failed - unexpected error %v", err)
+      }
+    }
+    if err := w.Flush(); err != nil {
+      t.Fatalf("output test 1 failed - unexpected error %v", err)
+    }
+    if b.String() != exp {
+      t.Errorf("output test 1 failed - format %d: expected\n%q got\n%q",
+        format, exp, b.String())
+    }
+  }
+
+  w := NewWriter(&strings.Builder{}, CSV, fields)
+  if err := w.Write("a.dat"); err == nil {
+    t.Error("output test 1 failed - accepted incomplete record")
+  }
+}
+
+
+
+// Tests for parsing the output format
+func Test_Format_1(t *testing.T) {
+
+  for name, expected := range map[string]Format{"text": Text, "JSON": JSON,
+    "csv": CSV, "tsv": TSV, "table": Table} {
+    if f, err := ParseFormat(name); err != nil || f != expected {
+      t.Errorf("output test 2 failed - %s: expected %d got %d", name,
+        expected, f)
+    }
+  }
+
+  if _, err := ParseFormat("xml"); err == nil {
+    t.Error("output test 2 failed - accepted unknown format")
+  }
+}
+
+
+
+// Tests for float formats
+func Test_Format_2(t *testing.T) {
+
+  tests := []struct {
+    spec string
+    value float64
+    expected string
+  }{
+    {"full", 0.1, "0.1"}, {"", 1e-12, "1e-12"},
+    {"full", 0.30000000000000004, "0.30000000000000004"},
+    {"%g", 1e-12, "1e-12"}, {"%.3e", 12345.678, "1.235e+04"},
+    {"%8.4f", 1.5, "  1.5000"}, {"%G", 1e-12, "1E-12"},
+  }
+
+  for _, test := range tests {
+    f, err := ParseFloatFormat(test.spec)
+    if err != nil || f.Format(test.value) != test.expected {
+      t.Errorf("output test 3 failed - %s: expected %q got %q (%v)",
+        test.spec, test.expected, f.Format(test.value), err)
+    }
+  }
+
+  for _, spec := range []string{"%d", "%s", "8.4f", "%8.4f%s"} {
+    if _, err := ParseFloatFormat(spec); err == nil {
+      t.Errorf("output test 3 failed - accepted invalid format %q", spec)
+    }
+  }
+
+  var b strings.Builder
+  w := NewWriter(&b, CSV, []string{"x"})
+  f, _ := ParseFloatFormat("%10.2f")
+  w.SetFloatFormat(f)
+  w.Write(1.0/3.0)
+  w.Flush()
+  if b.String() != "x\n0.33\n" {
+    t.Errorf("output test 3 failed - expected padding to be removed got %q",
+      b.String())
+  }
+}
@@ -0,0 +1,58 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package parser
+
+import (
+  "bufio"
+  "bytes"
+  "io"
+  "strings"
+)
+
+
+
+// SplitBlocks reads r completely and splits it into blocks of lines, e.g.,
+// to separate several data series concatenated into a single stream. With
+// an empty separator blocks are separated by one or more blank lines.
+// Otherwise each line starting with separator (ignoring leading
+// whitespace) begins a new block and is kept as its first line so it may
+// serve as a comment or header line.
+// Blocks without any non-blank lines are dropped.
+func SplitBlocks(r io.Reader, separator string) ([]io.Reader, error) {
+
+  var blocks []io.Reader
+  var block bytes.Buffer
+  content := false
+  flush := func() {
+    if content {
+      blocks = append(blocks, bytes.NewReader(block.Bytes()))
+    }
+    block = bytes.Buffer{}
+    content = false
+  }
+
+  scanner := bufio.NewScanner(r)
+  for scanner.Scan() {
+    text := scanner.Text()
+    trimmed := strings.TrimSpace(text)
+    blank := trimmed == ""
+    if separator == "" && blank {
+      flush()
+      continue
+    } else if separator != "" && strings.HasPrefix(trimmed, separator) {
+      flush()
+    }
+
+    block.WriteString(text)
+    block.WriteByte('\n')
+    content = content || !blank
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, err
+  }
+
+  flush()
+  return blocks, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package parser
+
+import (
+  "errors"
+  "fmt"
+  "strings"
+)
+
+
+
+// ErrMissingField signals a data line with fewer fields than required by
+// the requested column
+var ErrMissingField = errors.New("line has too few fields")
+
+
+
+// ParseError describes a failure to parse an input file
+type ParseError struct {
+  File string
+  Line int          // line number starting at 1
+  Column int        // column index, -1 if not specific to a single field
+  Token string      // offending field
+  Err error
+}
+
+
+
+// Error returns a description of the parse error including its location
+func (e *ParseError) Error() string {
+  name := Label(e.File)
+  if e.Column < 0 {
+    return fmt.Sprintf("%s:%d: %v", name, e.Line, e.Err)
+  } else if e.Token == "" {
+    return fmt.Sprintf("%s:%d: column %d: %v", name, e.Line, e.Column, e.Err)
+  }
+  return fmt.Sprintf("%s:%d: column %d: %q: %v", name, e.Line, e.Column,
+    e.Token, e.Err)
+}
+
+
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+  return e.Err
+}
+
+
+
+// Errors collects the errors of all input files which could not be
+// processed
+type Errors []error
+
+
+
+// Error returns the descriptions of all errors one per line
+func (e Errors) Error() string {
+  msgs := make([]string, len(e))
+  for i, err := range e {
+    msgs[i] = err.Error()
+  }
+  return strings.Join(msgs, "\n")
+}
+
+
+
+// Unwrap returns the list of errors
+func (e Errors) Unwrap() []error {
+  return e
+}
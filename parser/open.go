@@ -0,0 +1,135 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package parser
+
+import (
+  "bufio"
+  "bytes"
+  "compress/bzip2"
+  "compress/gzip"
+  "fmt"
+  "io"
+  "os"
+  "os/exec"
+)
+
+
+
+// magic numbers identifying compressed input
+var (
+  gzipMagic = []byte{0x1f, 0x8b}
+  bzip2Magic = []byte("BZh")
+  xzMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+
+
+// file wraps an opened input file and the decompressor reading from it
+type file struct {
+  io.Reader
+  closers []func() error
+}
+
+
+
+// Close closes the decompressor and the underlying file
+func (f *file) Close() error {
+  var err error
+  for i := len(f.closers)-1; i >= 0; i-- {
+    if e := f.closers[i](); e != nil && err == nil {
+      err = e
+    }
+  }
+  return err
+}
+
+
+
+// Open opens the named input file for reading. An empty name or "-"
+// denotes stdin. Input compressed with gzip, bzip2 or xz is detected by
+// its magic bytes and decompressed on the fly.
+//
+// NOTE: Go's standard library does not provide an xz decoder, hence xz
+//       compressed input is piped through an external "xz -dc".
+func Open(name string) (io.ReadCloser, error) {
+
+  var raw *os.File
+  if name == "" || name == "-" {
+    raw = os.Stdin
+  } else {
+    var err error
+    if raw, err = os.Open(name); err != nil {
+      return nil, err
+    }
+  }
+
+  f, err := decompress(raw)
+  if err != nil {
+    raw.Close()
+    return nil, fmt.Errorf("%s: %w", Label(name), err)
+  }
+  return f, nil
+}
+
+
+
+// Label returns the name of an input as used in messages and results,
+// i.e., <stdin> for stdin
+func Label(name string) string {
+  if name == "" || name == "-" {
+    return "<stdin>"
+  }
+  return name
+}
+
+
+
+// IsCompressed returns true if the leading bytes head of an input
+// identify it as compressed with gzip, bzip2 or xz
+func IsCompressed(head []byte) bool {
+  return bytes.HasPrefix(head, gzipMagic) ||
+    bytes.HasPrefix(head, bzip2Magic) || bytes.HasPrefix(head, xzMagic)
+}
+
+
+
+// decompress inspects the first few bytes of r and wraps it into the
+// matching decompressor, if any
+func decompress(r io.ReadCloser) (*file, error) {
+
+  buf := bufio.NewReader(r)
+  head, _ := buf.Peek(len(xzMagic))
+  f := &file{Reader: buf, closers: []func() error{r.Close}}
+
+  switch {
+  case bytes.HasPrefix(head, gzipMagic):
+    gz, err := gzip.NewReader(buf)
+    if err != nil {
+      return nil, err
+    }
+    f.Reader = gz
+    f.closers = append(f.closers, gz.Close)
+
+  case bytes.HasPrefix(head, bzip2Magic):
+    f.Reader = bzip2.NewReader(buf)
+
+  case bytes.HasPrefix(head, xzMagic):
+    cmd := exec.Command("xz", "-dc")
+    cmd.Stdin = buf
+    out, err := cmd.StdoutPipe()
+    if err != nil {
+      return nil, err
+    }
+    if err := cmd.Start(); err != nil {
+      return nil, fmt.Errorf("failed to start xz decompressor: %v", err)
+    }
+    f.Reader = out
+    f.closers = append(f.closers, func() error {
+      out.Close()
+      return cmd.Wait()
+    })
+  }
+  return f, nil
+}
@@ -0,0 +1,425 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package parser provides the line scanner shared by all analysis
+// packages. It takes care of comment lines, blank lines and header lines
+// and hands the remaining data lines to the caller split into fields.
+// Fields are separated by whitespace or a user supplied delimiter.
+//
+package parser
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+)
+
+
+
+// Config describes how the lines of an input file should be interpreted
+type Config struct {
+  Comments []string        // line prefixes marking comment lines
+  SkipBlank bool           // ignore lines consisting only of whitespace
+  HeaderLines int          // number of header lines preceding the data
+  Delimiter string         // field separator, empty for runs of whitespace
+  Missing MissingPolicy    // handling of missing values
+  MissingTokens []string   // fields denoting missing values, nil for the
+                           // DefaultMissing tokens and NaN (unless
+                           // Missing is MissingError)
+  Fill float64             // substitute for missing values with MissingFill
+}
+
+
+
+// DefaultConfig returns the configuration used if the user does not
+// request anything else: lines starting with '#' are comments and blank
+// lines are ignored
+func DefaultConfig() Config {
+  return Config{Comments: []string{"#"}, SkipBlank: true}
+}
+
+
+
+// Scanner reads an input stream line by line and returns the fields of
+// each data line, i.e., each line which is neither a comment, blank or
+// header line.
+//
+// NOTE: Header lines are the first HeaderLines lines of the input which
+//       are not comment or blank lines. The fields of the last header
+//       line are available via Header once the first data line was
+//       scanned.
+type Scanner struct {
+  name string
+  conf Config
+  scanner *bufio.Scanner
+  line int
+  fields []string
+  header []string
+}
+
+
+
+// NewScanner returns a new Scanner reading from r. The name of the input
+// is used to report errors.
+func NewScanner(r io.Reader, name string, conf Config) *Scanner {
+  return &Scanner{name: name, conf: conf, scanner: bufio.NewScanner(r)}
+}
+
+
+
+// Scan advances the Scanner to the next data line. It returns false
+// once the end of the input is reached or an error occurred
+func (s *Scanner) Scan() bool {
+
+  headers := 0
+  if s.line == 0 {
+    headers = s.conf.HeaderLines
+  }
+
+  for s.scanner.Scan() {
+    s.line++
+    text := s.scanner.Text()
+    if s.skip(text) {
+      continue
+    }
+
+    s.fields = split_fields(text, s.conf.Delimiter)
+    if headers > 0 {
+      s.header = s.fields
+      headers--
+      continue
+    }
+    return true
+  }
+  return false
+}
+
+
+
+// skip returns true if the line is a comment or blank line
+func (s *Scanner) skip(text string) bool {
+
+  trimmed := strings.TrimSpace(text)
+  if trimmed == "" {
+    return s.conf.SkipBlank
+  }
+
+  for _, prefix := range s.conf.Comments {
+    if prefix != "" && strings.HasPrefix(trimmed, prefix) {
+      return true
+    }
+  }
+  return false
+}
+
+
+
+// ParseDelimiter turns a delimiter specification as provided by the user
+// into the field separator. Besides the literal separator the names
+// comma (or csv), tab (or tsv), semicolon and whitespace are understood.
+func ParseDelimiter(spec string) string {
+  switch strings.ToLower(spec) {
+  case "comma", "csv":
+    return ","
+  case "tab", "tsv", "\\t":
+    return "\t"
+  case "semicolon":
+    return ";"
+  case "whitespace", "space":
+    return ""
+  }
+  return spec
+}
+
+
+
+// split_fields splits a line into fields. An empty delimiter splits at
+// runs of whitespace. Otherwise fields are separated by the delimiter and
+// may be enclosed in double quotes as in CSV files in which case the
+// delimiter may be part of the field and "" denotes a literal quote.
+// Whitespace surrounding unquoted fields is removed.
+func split_fields(text, delimiter string) []string {
+
+  if delimiter == "" {
+    return strings.Fields(text)
+  }
+
+  // whitespace which is part of the delimiter must not be trimmed
+  space := strings.Map(func(r rune) rune {
+    if strings.ContainsRune(delimiter, r) {
+      return -1
+    }
+    return r
+  }, " \t")
+
+  var fields []string
+  for {
+    text = strings.TrimLeft(text, space)
+    var field string
+    if strings.HasPrefix(text, "\"") {
+      field, text = split_quoted(text[1:])
+      if i := strings.Index(text, delimiter); i != -1 {
+        text = text[i:]
+      } else {
+        text = ""
+      }
+    } else if i := strings.Index(text, delimiter); i != -1 {
+      field, text = strings.Trim(text[:i], space), text[i:]
+    } else {
+      field, text = strings.Trim(text, space), ""
+    }
+    fields = append(fields, field)
+
+    if text == "" {
+      return fields
+    }
+    text = text[len(delimiter):]
+  }
+}
+
+
+
+// split_quoted returns the content of a quoted field and the remainder of
+// the line following the closing quote. text starts right after the
+// opening quote.
+func split_quoted(text string) (string, string) {
+
+  var field strings.Builder
+  for {
+    i := strings.Index(text, "\"")
+    if i == -1 {
+      // unterminated quote - take the remainder of the line
+      field.WriteString(text)
+      return field.String(), ""
+    }
+
+    field.WriteString(text[:i])
+    text = text[i+1:]
+    if !strings.HasPrefix(text, "\"") {
+      return field.String(), text
+    }
+    field.WriteString("\"")
+    text = text[1:]
+  }
+}
+
+
+
+// Fields returns the fields of the most recently scanned data line
+func (s *Scanner) Fields() []string {
+  return s.fields
+}
+
+
+
+// Line returns the line number of the most recently scanned line
+// starting at 1
+func (s *Scanner) Line() int {
+  return s.line
+}
+
+
+
+// Header returns the fields of the last header line or nil if there
+// were no header lines
+func (s *Scanner) Header() []string {
+  return s.header
+}
+
+
+
+// Columns resolves the requested columns against the header. It needs to
+// be called after the first data line has been scanned.
+func (s *Scanner) Columns(columns []Column) ([]int, error) {
+  indices, err := ResolveColumns(columns, s.header)
+  if err != nil {
+    return nil, s.error(-1, "", err)
+  }
+  return indices, nil
+}
+
+
+
+// Value parses the field in column colID of the most recently scanned data
+// line. Missing values are handled as described for Config.Value.
+func (s *Scanner) Value(colID int) (float64, bool, error) {
+
+  if colID >= len(s.fields) {
+    return 0.0, false, s.error(colID, "", ErrMissingField)
+  }
+
+  val, missing, err := s.conf.Value(s.fields[colID])
+  if err != nil {
+    return 0.0, false, s.error(colID, s.fields[colID], err)
+  }
+  return val, missing, nil
+}
+
+
+
+// Err returns the first non-EOF error encountered by the Scanner
+func (s *Scanner) Err() error {
+  if err := s.scanner.Err(); err != nil {
+    return s.error(-1, "", err)
+  }
+  return nil
+}
+
+
+
+// FieldError returns a ParseError describing an invalid value in column
+// colID of the most recently scanned data line
+func (s *Scanner) FieldError(colID int, err error) error {
+  token := ""
+  if colID >= 0 && colID < len(s.fields) {
+    token = s.fields[colID]
+  }
+  return s.error(colID, token, err)
+}
+
+
+
+// error returns a ParseError for the current line
+func (s *Scanner) error(colID int, token string, err error) error {
+  return &ParseError{File: s.name, Line: s.line, Column: colID, Token: token,
+    Err: err}
+}
+
+
+
+// Column identifies a data column either by its index (starting at 0 for
+// the leftmost column) or by its name in the header line
+type Column struct {
+  Index int
+  Name string
+}
+
+
+
+// ParseColumn turns a column specification as provided by the user into
+// a Column. Integer specifications are taken as column indices, anything
+// else as a column name.
+func ParseColumn(spec string) Column {
+  spec = strings.TrimSpace(spec)
+  if index, err := strconv.Atoi(spec); err == nil {
+    return Column{Index: index}
+  }
+  return Column{Name: spec}
+}
+
+
+
+// ParseColumns turns a comma separated list of column specifications
+// into a list of Columns. Besides single indices and names the list may
+// contain ranges of indices such as 5-9 (including both end points).
+func ParseColumns(spec string) ([]Column, error) {
+
+  var columns []Column
+  for _, item := range strings.Split(spec, ",") {
+    item = strings.TrimSpace(item)
+    if item == "" {
+      return nil, fmt.Errorf("empty column in column list %q", spec)
+    }
+
+    if first, last, ok := parse_range(item); ok {
+      if first > last {
+        return nil, fmt.Errorf("invalid column range %q", item)
+      }
+      for i := first; i <= last; i++ {
+        columns = append(columns, Column{Index: i})
+      }
+      continue
+    }
+
+    columns = append(columns, ParseColumn(item))
+  }
+  return columns, nil
+}
+
+
+
+// parse_range parses a column range of the form first-last. The returned
+// bool is false if item is not a range.
+func parse_range(item string) (int, int, bool) {
+
+  bounds := strings.SplitN(item, "-", 2)
+  if len(bounds) != 2 {
+    return 0, 0, false
+  }
+
+  first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+  if err != nil {
+    return 0, 0, false
+  }
+  last, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+  if err != nil {
+    return 0, 0, false
+  }
+  return first, last, true
+}
+
+
+
+// ResolveColumns returns the indices of all columns given the fields of
+// the header line
+func ResolveColumns(columns []Column, header []string) ([]int, error) {
+
+  indices := make([]int, len(columns))
+  for i, c := range columns {
+    var err error
+    if indices[i], err = c.Resolve(header); err != nil {
+      return nil, err
+    }
+  }
+  return indices, nil
+}
+
+
+
+// Resolve returns the index of the column given the fields of the
+// header line. Columns selected by name require a header line containing
+// the name exactly once.
+func (c Column) Resolve(header []string) (int, error) {
+
+  if c.Name == "" {
+    if c.Index < 0 {
+      return 0, fmt.Errorf("invalid column index %d", c.Index)
+    }
+    return c.Index, nil
+  }
+
+  if header == nil {
+    return 0, fmt.Errorf("column %q selected by name but input has no " +
+      "header line", c.Name)
+  }
+
+  index := -1
+  for i, name := range header {
+    if name != c.Name {
+      continue
+    }
+    if index != -1 {
+      return 0, fmt.Errorf("column name %q is ambiguous (columns %d and %d)",
+        c.Name, index, i)
+    }
+    index = i
+  }
+
+  if index == -1 {
+    return 0, fmt.Errorf("no column named %q in header", c.Name)
+  }
+  return index, nil
+}
+
+
+
+// String returns the column name or index
+func (c Column) String() string {
+  if c.Name != "" {
+    return c.Name
+  }
+  return strconv.Itoa(c.Index)
+}
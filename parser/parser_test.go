@@ -0,0 +1,259 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package parser provides the line scanner shared by all analysis
+// packages.
+package parser
+
+import (
+  "io"
+  "math"
+  "os"
+  "strings"
+  "testing"
+)
+
+
+// Tests for comment, blank and header line handling
+func Test_Scanner_1(t *testing.T) {
+
+  file, err := os.Open("test_files/test_data_1.txt")
+  if err != nil {
+    t.Fatal("parser test 1 failed - could not open test file")
+  }
+  defer file.Close()
+
+  conf := Config{Comments: []string{"#", "%"}, SkipBlank: true, HeaderLines: 1}
+  scanner := NewScanner(file, "test_data_1.txt", conf)
+  expected := []string{"1.5", "2.5", "3.5"}
+  lines := []int{5, 8, 9}
+  count := 0
+  for scanner.Scan() {
+    if count >= len(expected) {
+      t.Fatalf("parser test 1 failed - too many data lines")
+    }
+    if scanner.Fields()[1] != expected[count] {
+      t.Errorf("parser test 1 failed - expected %v got %v", expected[count],
+        scanner.Fields()[1])
+    }
+    if scanner.Line() != lines[count] {
+      t.Errorf("parser test 1 failed - expected line %v got %v", lines[count],
+        scanner.Line())
+    }
+    count++
+  }
+
+  if count != len(expected) {
+    t.Errorf("parser test 1 failed - expected %v data lines got %v",
+      len(expected), count)
+  }
+
+  header := scanner.Header()
+  if len(header) != 2 || header[0] != "time" || header[1] != "energy" {
+    t.Errorf("parser test 1 failed - unexpected header %v", header)
+  }
+}
+
+
+
+// Tests for selecting columns by index and by name
+func Test_Column_1(t *testing.T) {
+
+  header := []string{"time", "energy", "pressure", "energy"}
+
+  if c := ParseColumn("2"); c.Name != "" || c.Index != 2 {
+    t.Errorf("column test 1 failed - expected index 2 got %v", c)
+  }
+
+  index, err := ParseColumn("pressure").Resolve(header)
+  if err != nil || index != 2 {
+    t.Errorf("column test 1 failed - expected index 2 got %v (%v)", index, err)
+  }
+
+  if _, err := ParseColumn("energy").Resolve(header); err == nil {
+    t.Error("column test 1 failed - ambiguous column name was accepted")
+  }
+
+  if _, err := ParseColumn("volume").Resolve(header); err == nil {
+    t.Error("column test 1 failed - missing column name was accepted")
+  }
+
+  if _, err := ParseColumn("time").Resolve(nil); err == nil {
+    t.Error("column test 1 failed - column name without header was accepted")
+  }
+}
+
+
+
+// Tests for parsing lists of columns
+func Test_Column_2(t *testing.T) {
+
+  columns, err := ParseColumns("1,3,5-7,energy")
+  if err != nil {
+    t.Fatalf("column test 2 failed - %v", err)
+  }
+
+  expected := []Column{{Index: 1}, {Index: 3}, {Index: 5}, {Index: 6},
+    {Index: 7}, {Name: "energy"}}
+  if len(columns) != len(expected) {
+    t.Fatalf("column test 2 failed - expected %v got %v", expected, columns)
+  }
+  for i, c := range columns {
+    if c != expected[i] {
+      t.Errorf("column test 2 failed - expected %v got %v", expected[i], c)
+    }
+  }
+
+  if _, err := ParseColumns("1,,2"); err == nil {
+    t.Error("column test 2 failed - empty column was accepted")
+  }
+
+  if _, err := ParseColumns("9-5"); err == nil {
+    t.Error("column test 2 failed - reversed range was accepted")
+  }
+}
+
+
+
+// Tests for splitting lines at delimiters
+func Test_Split_1(t *testing.T) {
+
+  tests := []struct {
+    text, delimiter string
+    expected []string
+  }{
+    {" 1.0   2.0\t3.0 ", "", []string{"1.0", "2.0", "3.0"}},
+    {"1.0, 2.0,3.0", ",", []string{"1.0", "2.0", "3.0"}},
+    {"1.0\t\t3.0", "\t", []string{"1.0", "", "3.0"}},
+    {`"a, b",2.0,"say ""hi""",`, ",", []string{"a, b", "2.0", `say "hi"`, ""}},
+    {"1.0 || 2.0", "||", []string{"1.0", "2.0"}},
+  }
+
+  for _, test := range tests {
+    fields := split_fields(test.text, test.delimiter)
+    if len(fields) != len(test.expected) {
+      t.Errorf("split test 1 failed - expected %q got %q", test.expected,
+        fields)
+      continue
+    }
+    for i, f := range fields {
+      if f != test.expected[i] {
+        t.Errorf("split test 1 failed - expected %q got %q", test.expected,
+          fields)
+        break
+      }
+    }
+  }
+
+  if ParseDelimiter("tab") != "\t" || ParseDelimiter("csv") != "," ||
+     ParseDelimiter("|") != "|" {
+    t.Error("split test 1 failed - failed to parse delimiter names")
+  }
+}
+
+
+
+// Tests for reading compressed input
+func Test_Open_1(t *testing.T) {
+
+  expected, err := os.ReadFile("test_files/test_data_1.txt")
+  if err != nil {
+    t.Fatal("open test 1 failed - could not read test file")
+  }
+
+  for _, name := range []string{"test_files/test_data_1.txt",
+    "test_files/test_data_2.gz", "test_files/test_data_3.bz2",
+    "test_files/test_data_4.xz"} {
+
+    file, err := Open(name)
+    if err != nil {
+      t.Errorf("open test 1 failed - could not open %v: %v", name, err)
+      continue
+    }
+
+    content, err := io.ReadAll(file)
+    file.Close()
+    if err != nil {
+      t.Errorf("open test 1 failed - could not read %v: %v", name, err)
+      continue
+    }
+    if string(content) != string(expected) {
+      t.Errorf("open test 1 failed - unexpected content of %v", name)
+    }
+  }
+
+  if _, err := Open("test_files/does_not_exist.txt"); err == nil {
+    t.Error("open test 1 failed - opening missing file succeeded")
+  }
+
+  if Label("") != "<stdin>" || Label("-") != "<stdin>" ||
+     Label("a.dat") != "a.dat" {
+    t.Error("open test 1 failed - wrong labels of inputs")
+  }
+}
+
+
+
+// Tests for splitting a stream into blocks
+func Test_Blocks_1(t *testing.T) {
+
+  input := "1 2\n3 4\n\n\n5 6\n  \n7 8\n\n"
+  blocks, err := SplitBlocks(strings.NewReader(input), "")
+  expected := []string{"1 2\n3 4\n", "5 6\n", "7 8\n"}
+  check_blocks(t, "parser test 6", blocks, err, expected)
+
+  input = "# run 1\n1 2\n# run 2\n3 4\n\n5 6\n"
+  blocks, err = SplitBlocks(strings.NewReader(input), "# run")
+  expected = []string{"# run 1\n1 2\n", "# run 2\n3 4\n\n5 6\n"}
+  check_blocks(t, "parser test 7", blocks, err, expected)
+}
+
+
+
+// Tests for the default missing value tokens
+func Test_Value_1(t *testing.T) {
+
+  conf := DefaultConfig()
+  if v, missing, err := conf.Value("nan"); err != nil || missing ||
+     !math.IsNaN(v) {
+    t.Errorf("parser test 8 failed - nan was not read as a number (%v)",
+      err)
+  }
+
+  if _, missing, err := conf.Value("NA"); err == nil || !missing {
+    t.Error("parser test 9 failed - NA was accepted as a value")
+  }
+
+  conf.Missing = MissingSkip
+  if _, missing, err := conf.Value("nan"); err != nil || !missing {
+    t.Errorf("parser test 10 failed - nan was not missing (%v)", err)
+  }
+
+  conf.MissingTokens = []string{"NA"}
+  if v, missing, err := conf.Value("NaN"); err != nil || missing ||
+     !math.IsNaN(v) {
+    t.Errorf("parser test 11 failed - NaN was not read as a number (%v)",
+      err)
+  }
+}
+
+
+
+// check_blocks compares the content of blocks with the expected strings
+func check_blocks(t *testing.T, name string, blocks []io.Reader, err error,
+  expected []string) {
+
+  if err != nil || len(blocks) != len(expected) {
+    t.Fatalf("%s failed - expected %d blocks got %d (%v)", name,
+      len(expected), len(blocks), err)
+  }
+
+  for i, block := range blocks {
+    content, _ := io.ReadAll(block)
+    if string(content) != expected[i] {
+      t.Errorf("%s failed - expected block %q got %q", name, expected[i],
+        content)
+    }
+  }
+}
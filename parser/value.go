@@ -0,0 +1,98 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package parser
+
+import (
+  "fmt"
+  "math"
+  "strconv"
+  "strings"
+)
+
+
+
+// MissingPolicy describes how missing values in a data column are handled
+type MissingPolicy int
+
+const (
+  MissingError MissingPolicy = iota   // reject the file
+  MissingSkip                         // skip the row
+  MissingNaN                          // use NaN and let it propagate
+  MissingFill                         // substitute Config.Fill
+)
+
+
+
+// DefaultMissing lists the field values which denote a missing value
+// unless the user provides a different list. The comparison is case
+// insensitive.
+//
+// NOTE: Without a token list NaN also denotes a missing value unless the
+//       policy is MissingError. NaN thus stays a valid float for
+//       existing inputs relying on the default policy.
+var DefaultMissing = []string{"NA", "N/A", "-", ""}
+
+
+
+// ParseMissingPolicy turns the name of a missing value policy (error,
+// skip, nan or fill) into a MissingPolicy
+func ParseMissingPolicy(name string) (MissingPolicy, error) {
+  switch strings.ToLower(name) {
+  case "error":
+    return MissingError, nil
+  case "skip":
+    return MissingSkip, nil
+  case "nan":
+    return MissingNaN, nil
+  case "fill":
+    return MissingFill, nil
+  }
+  return MissingError, fmt.Errorf("unknown missing value policy %q", name)
+}
+
+
+
+// Value converts a field into a float. If the field denotes a missing
+// value the returned bool is true and the value is determined by the
+// missing value policy. For MissingSkip the caller is expected to skip
+// the row.
+func (c Config) Value(field string) (float64, bool, error) {
+
+  field = strings.TrimSpace(field)
+  if c.is_missing(field) {
+    switch c.Missing {
+    case MissingError:
+      return 0.0, true, fmt.Errorf("missing value %q", field)
+    case MissingNaN:
+      return math.NaN(), true, nil
+    case MissingFill:
+      return c.Fill, true, nil
+    }
+    return 0.0, true, nil
+  }
+
+  val, err := strconv.ParseFloat(field, 64)
+  return val, false, err
+}
+
+
+
+// is_missing returns true if field denotes a missing value
+func (c Config) is_missing(field string) bool {
+  tokens := c.MissingTokens
+  if tokens == nil {
+    if c.Missing != MissingError && strings.EqualFold(field, "NaN") {
+      return true
+    }
+    tokens = DefaultMissing
+  }
+
+  for _, token := range tokens {
+    if strings.EqualFold(field, token) {
+      return true
+    }
+  }
+  return false
+}
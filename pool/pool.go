@@ -0,0 +1,145 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package pool processes a list of input files concurrently with a fixed
+// number of workers. It is shared by all analysis packages which treat
+// each input file independently.
+//
+// Stream hands the result of each file to the caller in input order as
+// soon as it is available, Collect and Process gather all results.
+//
+package pool
+
+import (
+  "io"
+  "github.com/haskelladdict/lizard/parser"
+)
+
+
+
+// fileResult contains the result of processing a single file or the
+// error encountered while doing so. The index refers to the position of
+// the file in the list of input files.
+type fileResult[T any] struct {
+  index int
+  value T
+  err error
+}
+
+
+
+// start_jobs processes the files whose indices are still in the queue
+// one by one. Each worker runs a separate start_jobs goroutine.
+func start_jobs[T any](files []string, fn func(string) (T, error),
+  jobs <-chan int, results chan<- fileResult[T]) {
+
+  for i := range jobs {
+    value, err := fn(files[i])
+    results <- fileResult[T]{i, value, err}
+  }
+}
+
+
+
+// Stream calls fn with the name of each of files using numWorkers
+// concurrent workers and passes its results on to emit in the order of
+// files independent of the order in which the workers finish. emit is
+// called from the calling goroutine only.
+//
+// NOTE: Results arriving ahead of their turn are held back until all
+//       preceding files are done.
+func Stream[T any](files []string, numWorkers int,
+  fn func(string) (T, error), emit func(T, error)) {
+
+  if len(files) < numWorkers {
+    numWorkers = len(files)
+  } else if numWorkers < 1 {
+    numWorkers = 1
+  }
+
+  jobs := make(chan int, len(files))
+  results := make(chan fileResult[T], numWorkers)
+  for i := range files {
+    jobs <- i
+  }
+  close(jobs)
+  for i := 0; i < numWorkers; i++ {
+    go start_jobs(files, fn, jobs, results)
+  }
+
+  waiting := make(map[int]fileResult[T])
+  for next := 0; next < len(files); {
+    result := <-results
+    waiting[result.index] = result
+    for r, ok := waiting[next]; ok; r, ok = waiting[next] {
+      delete(waiting, next)
+      emit(r.value, r.err)
+      next++
+    }
+  }
+}
+
+
+
+// Collect calls fn with the name of each of files using numWorkers
+// concurrent workers and returns the results in the order of files.
+//
+// NOTE: Files for which fn fails are left out of the results. Their
+//       errors are returned as parser.Errors in the order of files
+//       alongside the results of all other files.
+func Collect[T any](files []string, numWorkers int,
+  fn func(string) (T, error)) ([]T, error) {
+
+  output := make([]T, 0, len(files))
+  var errors parser.Errors
+  Stream(files, numWorkers, fn, func(value T, err error) {
+    if err != nil {
+      errors = append(errors, err)
+      return
+    }
+    output = append(output, value)
+  })
+
+  if len(errors) != 0 {
+    return output, errors
+  }
+  return output, nil
+}
+
+
+
+// Reading turns fn processing an input stream into a function processing
+// the named file which is opened via parser.Open, i.e., an empty file
+// name denotes stdin and compressed input is decompressed on the fly.
+func Reading[T any](fn func(io.Reader, string) (T,
+  error)) func(string) (T, error) {
+
+  return func(name string) (T, error) {
+    file, err := parser.Open(name)
+    if err != nil {
+      var zero T
+      return zero, err
+    }
+    defer file.Close()
+
+    return fn(file, name)
+  }
+}
+
+
+
+// Process opens each of files and passes its content together with the
+// file name to fn using numWorkers concurrent workers. The results are
+// returned in the order of files.
+//
+// NOTE: An empty file name denotes stdin.
+//
+// NOTE: Files which can not be opened or for which fn fails are left
+//       out of the results. Their errors are returned as parser.Errors
+//       in the order of files alongside the results of all other files.
+func Process[T any](files []string, numWorkers int,
+  fn func(io.Reader, string) (T, error)) ([]T, error) {
+
+  return Collect(files, numWorkers, Reading(fn))
+}
@@ -0,0 +1,82 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+  "errors"
+  "io"
+  "strings"
+  "testing"
+  "time"
+  "github.com/haskelladdict/lizard/parser"
+)
+
+
+
+// Tests for ordering of results and collection of errors
+func Test_Process_1(t *testing.T) {
+
+  files := []string{"test_files/test_data_1.txt",
+    "test_files/does_not_exist.txt", "test_files/test_data_2.txt",
+    "test_files/test_data_1.txt"}
+  read := func(r io.Reader, name string) (string, error) {
+    content, err := io.ReadAll(r)
+    return name + ":" + strings.TrimSpace(string(content)), err
+  }
+
+  for _, workers := range []int{1, 2, 8} {
+    result_1, err := Process(files, workers, read)
+    if errs, ok := err.(parser.Errors); !ok || len(errs) != 1 {
+      t.Errorf("Pool test 1 failed - unexpected error %v", err)
+    }
+    expected := []string{files[0] + ":1\n2", files[2] + ":3",
+      files[3] + ":1\n2"}
+    if len(result_1) != len(expected) {
+      t.Fatalf("Pool test 1 failed - expected %q got %q", expected,
+        result_1)
+    }
+    for i, r := range result_1 {
+      if r != expected[i] {
+        t.Errorf("Pool test 1 failed - expected %q got %q", expected,
+          result_1)
+        break
+      }
+    }
+  }
+
+  // failures of fn are returned as parser.Errors
+  fail := errors.New("rejected")
+  result_2, err := Process(files[:1], 4,
+    func(io.Reader, string) (int, error) { return 0, fail })
+  if errs, ok := err.(parser.Errors); !ok || len(errs) != 1 ||
+     errs[0] != fail || len(result_2) != 0 {
+    t.Errorf("Pool test 2 failed - got %v (error %v)", result_2, err)
+  }
+
+  result_3, err := Process(nil, 4, read)
+  if err != nil || len(result_3) != 0 {
+    t.Errorf("Pool test 3 failed - got %v (error %v)", result_3, err)
+  }
+}
+
+
+
+// Tests for streaming results in input order
+func Test_Stream_1(t *testing.T) {
+
+  files := []string{"d", "c", "b", "a"}
+  delay := func(name string) (string, error) {
+    time.Sleep(time.Duration(name[0] - 'a')*time.Millisecond)
+    return name, nil
+  }
+
+  var result_4 []string
+  Stream(files, 4, delay, func(name string, err error) {
+    result_4 = append(result_4, name)
+  })
+  if strings.Join(result_4, "") != "dcba" {
+    t.Errorf("Pool test 4 failed - expected dcba got %q", result_4)
+  }
+}
@@ -9,6 +9,7 @@ package quickselect
 
 import (
   "math/rand"
+  "sort"
   "time"
 )
 
@@ -33,6 +34,31 @@ func Quickselect(array []float64, k int) float64 {
 
 
 
+// Multiselect selects the items of rank ks (the k smallest items for each
+// k in ks) from array in a single randomized partitioning pass. This is
+// cheaper than calling Quickselect for each k separately since
+// partitions not containing any requested rank are never revisited.
+// The selected items are returned in the order of ks.
+// NOTE: all ks have to be valid indices within slice array. array is
+//       reordered in place.
+func Multiselect(array []float64, ks []int) []float64 {
+
+  ranks := make([]int, len(ks))
+  copy(ranks, ks)
+  sort.Ints(ranks)
+
+  r := rand.New(rand.NewSource(time.Now().UnixNano()))
+  multiselect_h(array, ranks, 0, len(array), r)
+
+  items := make([]float64, len(ks))
+  for i, k := range ks {
+    items[i] = array[k]
+  }
+  return items
+}
+
+
+
 // quicksort_h is the main recursive quicksort routine
 func quickselect_h(array []float64, k int, first int, last int, r *rand.Rand) float64 {
 
@@ -52,6 +78,24 @@ func quickselect_h(array []float64, k int, first int, last int, r *rand.Rand) fl
 
 
 
+// multiselect_h is the main recursive multiselect routine. It only
+// descends into partitions containing at least one of the sorted ranks ks.
+func multiselect_h(array []float64, ks []int, first int, last int,
+  r *rand.Rand) {
+
+  if len(ks) == 0 || first >= last-1 {
+    return
+  }
+
+  pivot := partition_items(array, first, last, r)
+  lower := sort.SearchInts(ks, pivot)
+  upper := sort.SearchInts(ks, pivot+1)
+  multiselect_h(array, ks[:lower], first, pivot, r)
+  multiselect_h(array, ks[upper:], pivot+1, last, r)
+}
+
+
+
 // quicksort_h is the main recursive quicksort routine
 func quicksort_h(array []float64, first int, last int, r *rand.Rand) {
 
@@ -24,7 +24,7 @@ func Test_Average_1(t *testing.T) {
   data_file_1 := "test_files/test_data_1.txt"
   items, err := read_file_into_slice(data_file_1)
   if err != nil {
-    t.Error("quickselect test 1 failed - error parsing %v", data_file_1)
+    t.Errorf("quickselect test 1 failed - error parsing %v", data_file_1)
   }
 
   for k := 0; k < 100; k++ {
@@ -64,6 +64,28 @@ func Test_Average_2(t *testing.T) {
 }
 
 
+func Test_Multiselect_1(t *testing.T) {
+
+  data_file_1 := "test_files/test_data_1.txt"
+  items, err := read_file_into_slice(data_file_1)
+  if err != nil {
+    t.Errorf("multiselect test 1 failed - error parsing %v", data_file_1)
+  }
+
+  ks := []int{9, 0, 5, 6, 5}
+  expected := []float64{10, 1, 6, 7, 6}
+  for k := 0; k < 100; k++ {
+    result := Multiselect(items, ks)
+    for i, v := range result {
+      if !float_equal(v, expected[i]) {
+        t.Errorf("multiselect test 1 failed - expected %v got %v\n",
+          expected[i], v)
+      }
+    }
+  }
+}
+
+
 /*
 // Benchmarks
 func Benchmark_Average(t *testing.B) {
@@ -0,0 +1,242 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package sketch provides streaming estimators for order statistics
+// (medians, quantiles) of datasets too large to be kept in memory.
+//
+// The KLL sketch of Karnin, Lang and Liberty (Optimal Quantile
+// Approximation in Streams, FOCS 2016) keeps O(k log(n/k)) items for a
+// stream of n items. A quantile estimate for probability p is an item of
+// the stream whose normalized rank differs from p by at most the rank
+// error with high probability. The rank error decreases roughly as 1/k,
+// e.g. about 1.3% for k = 200 at 99% confidence (see RankError).
+//
+package sketch
+
+import (
+  "math"
+  "math/rand"
+  "sort"
+)
+
+
+
+// DefaultK is the default accuracy parameter of the KLL sketch
+const DefaultK = 200
+
+
+
+// capacity_ratio is the ratio between the capacities of two successive
+// compactor levels
+const capacity_ratio = 2.0/3.0
+
+
+
+// seed initializes the random number generator of each sketch so results
+// are reproducible between runs
+const seed = 1
+
+
+
+// KLL is a KLL quantile sketch. Items in compactor level h represent 2^h
+// items of the original stream.
+type KLL struct {
+  k int
+  compactors [][]float64
+  size int            // number of items currently stored
+  max_size int        // total capacity of all compactors
+  count int           // number of items added to the sketch
+  nan bool            // true if the stream contained NaN values
+  rng *rand.Rand
+}
+
+
+
+// NewKLL returns an empty KLL sketch with accuracy parameter k
+func NewKLL(k int) *KLL {
+  if k < 2 {
+    k = 2
+  }
+  s := &KLL{k: k, rng: rand.New(rand.NewSource(seed))}
+  s.grow()
+  return s
+}
+
+
+
+// grow adds a new compactor level on top of the sketch
+func (s *KLL) grow() {
+  s.compactors = append(s.compactors, nil)
+  s.max_size = 0
+  for h := range s.compactors {
+    s.max_size += s.capacity(h)
+  }
+}
+
+
+
+// capacity returns the number of items compactor level h can hold
+// before it needs to be compacted
+func (s *KLL) capacity(h int) int {
+  depth := len(s.compactors) - h - 1
+  return int(math.Ceil(math.Pow(capacity_ratio, float64(depth))*
+    float64(s.k))) + 1
+}
+
+
+
+// Update adds an item to the sketch
+func (s *KLL) Update(x float64) {
+
+  s.count++
+  if math.IsNaN(x) {
+    s.nan = true
+    return
+  }
+
+  s.compactors[0] = append(s.compactors[0], x)
+  s.size++
+  if s.size >= s.max_size {
+    s.compress()
+  }
+}
+
+
+
+// compress compacts full levels until the sketch is below its capacity
+func (s *KLL) compress() {
+
+  for h := 0; h < len(s.compactors); h++ {
+    if len(s.compactors[h]) < s.capacity(h) {
+      continue
+    }
+    if h+1 >= len(s.compactors) {
+      s.grow()
+    }
+    s.compact(h)
+
+    if s.size < s.max_size {
+      break
+    }
+  }
+}
+
+
+
+// compact sorts level h and promotes every other item to level h+1. The
+// choice of the odd or even items is random which makes the sketch
+// unbiased. For an odd number of items the smallest one stays behind.
+func (s *KLL) compact(h int) {
+
+  items := s.compactors[h]
+  sort.Float64s(items)
+
+  odd := len(items) % 2
+  offset := odd + s.rng.Intn(2)
+  for i := offset; i < len(items); i += 2 {
+    s.compactors[h+1] = append(s.compactors[h+1], items[i])
+  }
+  s.compactors[h] = items[:odd]
+
+  s.size = 0
+  for _, c := range s.compactors {
+    s.size += len(c)
+  }
+}
+
+
+
+// Merge adds all items of sketch o to sketch s. Both sketches should have
+// the same accuracy parameter.
+func (s *KLL) Merge(o *KLL) {
+
+  for len(s.compactors) < len(o.compactors) {
+    s.grow()
+  }
+
+  for h, c := range o.compactors {
+    s.compactors[h] = append(s.compactors[h], c...)
+    s.size += len(c)
+  }
+  s.count += o.count
+  s.nan = s.nan || o.nan
+
+  for s.size >= s.max_size {
+    s.compress()
+  }
+}
+
+
+
+// Count returns the number of items added to the sketch
+func (s *KLL) Count() int {
+  return s.count
+}
+
+
+
+// RankError returns the normalized rank error of a single quantile
+// estimate at 99% confidence. The expression is the empirical fit used
+// by Apache DataSketches for KLL sketches.
+func (s *KLL) RankError() float64 {
+  return 2.296/math.Pow(float64(s.k), 0.9723)
+}
+
+
+
+// weighted_item is a stored item together with the number of stream items
+// it represents
+type weighted_item struct {
+  value float64
+  weight int
+}
+
+
+
+// Quantiles returns estimates for the quantiles of the stream at
+// probabilities probs. An empty stream or one containing NaN yields NaN.
+func (s *KLL) Quantiles(probs []float64) []float64 {
+
+  quants := make([]float64, len(probs))
+  if s.size == 0 || s.nan {
+    for i := range quants {
+      quants[i] = math.NaN()
+    }
+    return quants
+  }
+
+  items := make([]weighted_item, 0, s.size)
+  total := 0
+  for h, c := range s.compactors {
+    for _, v := range c {
+      items = append(items, weighted_item{v, 1 << uint(h)})
+      total += 1 << uint(h)
+    }
+  }
+  sort.Slice(items, func(i, j int) bool {
+    return items[i].value < items[j].value
+  })
+
+  for i, p := range probs {
+    target := p*float64(total)
+    cum := 0
+    quants[i] = items[len(items)-1].value
+    for _, item := range items {
+      cum += item.weight
+      if float64(cum) >= target {
+        quants[i] = item.value
+        break
+      }
+    }
+  }
+  return quants
+}
+
+
+
+// Quantile returns an estimate for the quantile of the stream at
+// probability p
+func (s *KLL) Quantile(p float64) float64 {
+  return s.Quantiles([]float64{p})[0]
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// Package sketch provides streaming estimators for order statistics
+package sketch
+
+import (
+  "math"
+  "math/rand"
+  "testing"
+)
+
+
+// Tests
+func Test_KLL_1(t *testing.T) {
+
+  n := 100000
+  data := rand.New(rand.NewSource(42)).Perm(n)
+
+  s := NewKLL(DefaultK)
+  for _, v := range data {
+    s.Update(float64(v))
+  }
+
+  if s.Count() != n {
+    t.Errorf("KLL test 1 failed - expected count %v got %v", n, s.Count())
+  }
+
+  probs := []float64{0.0, 0.05, 0.25, 0.5, 0.75, 0.95, 1.0}
+  check_quantiles(t, "KLL test 1", s, probs, n)
+}
+
+
+func Test_KLL_2(t *testing.T) {
+
+  // merging the sketches of the two halves of a stream
+  n := 100000
+  data := rand.New(rand.NewSource(7)).Perm(n)
+
+  s1 := NewKLL(DefaultK)
+  s2 := NewKLL(DefaultK)
+  for i, v := range data {
+    if i < n/3 {
+      s1.Update(float64(v))
+    } else {
+      s2.Update(float64(v))
+    }
+  }
+  s1.Merge(s2)
+
+  if s1.Count() != n {
+    t.Errorf("KLL test 2 failed - expected count %v got %v", n, s1.Count())
+  }
+
+  probs := []float64{0.01, 0.1, 0.5, 0.9, 0.99}
+  check_quantiles(t, "KLL test 2", s1, probs, n)
+}
+
+
+func Test_KLL_3(t *testing.T) {
+
+  s := NewKLL(DefaultK)
+  if !math.IsNaN(s.Quantile(0.5)) {
+    t.Error("KLL test 3 failed - expected NaN for empty sketch")
+  }
+
+  s.Update(1.0)
+  if s.Quantile(0.5) != 1.0 {
+    t.Errorf("KLL test 3 failed - expected 1 got %v", s.Quantile(0.5))
+  }
+
+  s.Update(math.NaN())
+  if !math.IsNaN(s.Quantile(0.5)) {
+    t.Error("KLL test 3 failed - expected NaN for stream containing NaN")
+  }
+}
+
+
+// Support Functions
+
+// check_quantiles compares the quantile estimates of a sketch of a stream
+// of the integers 0 .. n-1 with the exact quantiles
+func check_quantiles(t *testing.T, name string, s *KLL, probs []float64,
+  n int) {
+
+  quants := s.Quantiles(probs)
+  for i, p := range probs {
+    rank := quants[i]/float64(n-1)
+    if math.Abs(rank - p) > s.RankError() {
+      t.Errorf("%s failed - quantile %v has rank %v", name, p, rank)
+    }
+  }
+}
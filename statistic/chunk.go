@@ -0,0 +1,220 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statistic
+
+import (
+  "bufio"
+  "errors"
+  "io"
+  "os"
+  "github.com/haskelladdict/lizard/parser"
+)
+
+
+
+// min_chunk_size is the smallest number of bytes per chunk worth
+// processing in a separate goroutine
+var min_chunk_size int64 = 1 << 20
+
+
+
+// chunk describes the byte range [begin, end) of a file
+type chunk struct {
+  begin, end int64
+}
+
+
+
+// FromFile computes the statistic of the requested columns of the named
+// file like FromReader. Large uncompressed files are split into up to
+// numWorkers chunks of complete lines which are processed concurrently.
+// The partial results of all chunks are combined via the pairwise update
+// of the moments.
+//
+// NOTE: stdin and compressed input can not be split and are processed
+//       sequentially.
+func FromFile(name string, opts Options, numWorkers int) ([]Stat, error) {
+
+  if err := opts.validate(); err != nil {
+    return nil, err
+  }
+
+  if name != "" && name != "-" && numWorkers > 1 {
+    stats, ok, err := from_chunks(name, opts, numWorkers)
+    if ok {
+      return stats, err
+    }
+  }
+
+  file, err := parser.Open(name)
+  if err != nil {
+    return nil, err
+  }
+  defer file.Close()
+
+  return FromReader(file, name, opts)
+}
+
+
+
+// from_chunks processes a regular uncompressed file in chunks. The
+// returned bool is false if the file is not suitable for splitting and
+// should be processed sequentially instead.
+func from_chunks(name string, opts Options, numWorkers int) ([]Stat, bool,
+  error) {
+
+  file, err := os.Open(name)
+  if err != nil {
+    return nil, true, err
+  }
+  defer file.Close()
+
+  info, err := file.Stat()
+  if err != nil {
+    return nil, true, err
+  }
+  size := info.Size()
+  num_chunks := int(size/min_chunk_size)
+  if num_chunks > numWorkers {
+    num_chunks = numWorkers
+  }
+  if !info.Mode().IsRegular() || num_chunks < 2 {
+    return nil, false, nil
+  }
+
+  head := make([]byte, 8)
+  n, _ := file.ReadAt(head, 0)
+  if parser.IsCompressed(head[:n]) {
+    return nil, false, nil
+  }
+
+  // resolve the columns via the header and locate the first data line
+  scanner := parser.NewScanner(io.NewSectionReader(file, 0, size), name,
+    opts.Parse)
+  if !scanner.Scan() {
+    return nil, false, nil
+  }
+  colIDs, err := scanner.Columns(opts.columns())
+  if err != nil {
+    return nil, true, err
+  }
+  skipped_lines := scanner.Line() - 1
+  begin, err := line_offset(file, size, skipped_lines)
+  if err != nil {
+    return nil, true, err
+  }
+
+  chunks, err := split_lines(file, begin, size, num_chunks)
+  if err != nil {
+    return nil, true, err
+  }
+
+  // all chunks are scanned as data lines only
+  conf := opts.Parse
+  conf.HeaderLines = 0
+
+  results := make([]chan partial, len(chunks))
+  for i, c := range chunks {
+    results[i] = make(chan partial, 1)
+    go func(c chunk, result chan<- partial) {
+      r := io.NewSectionReader(file, c.begin, c.end - c.begin)
+      result <- scan(parser.NewScanner(r, name, conf), colIDs, opts)
+    }(c, results[i])
+  }
+
+  // combine the chunks in file order. Line numbers of parse errors are
+  // relative to their chunk and need to be shifted accordingly.
+  p := new_partial(opts)
+  p.lines = skipped_lines
+  for _, result := range results {
+    q := <-result
+    if p.err != nil {
+      continue
+    } else if q.err != nil {
+      var perr *parser.ParseError
+      if errors.As(q.err, &perr) {
+        perr.Line += p.lines
+      }
+      p.err = q.err
+      continue
+    }
+    p.merge(q)
+  }
+
+  if p.err != nil {
+    return nil, true, p.err
+  }
+  return p.stats(name, opts), true, nil
+}
+
+
+
+// line_offset returns the byte offset of the line following the first
+// num_lines lines of r
+func line_offset(r io.ReaderAt, size int64, num_lines int) (int64, error) {
+
+  reader := bufio.NewReader(io.NewSectionReader(r, 0, size))
+  var offset int64
+  for i := 0; i < num_lines; i++ {
+    line, err := reader.ReadBytes('\n')
+    offset += int64(len(line))
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return 0, err
+    }
+  }
+  return offset, nil
+}
+
+
+
+// split_lines splits the byte range [begin, end) of r into up to n chunks
+// of roughly equal size. Chunk boundaries are moved forward to the start
+// of the next line so each chunk consists of complete lines.
+func split_lines(r io.ReaderAt, begin, end int64, n int) ([]chunk, error) {
+
+  var chunks []chunk
+  start := begin
+  for i := 1; i <= n && start < end; i++ {
+    stop := end
+    if i < n {
+      var err error
+      if stop, err = next_line(r, begin + int64(i)*(end - begin)/int64(n),
+        end); err != nil {
+        return nil, err
+      }
+    }
+    if stop > start {
+      chunks = append(chunks, chunk{start, stop})
+      start = stop
+    }
+  }
+  return chunks, nil
+}
+
+
+
+// next_line returns the offset of the first line starting at or after
+// offset pos of r
+func next_line(r io.ReaderAt, pos, end int64) (int64, error) {
+
+  if pos <= 0 {
+    return 0, nil
+  }
+
+  // the line starts at pos if the preceding byte is a newline
+  reader := bufio.NewReader(io.NewSectionReader(r, pos-1, end - pos + 1))
+  skip, err := reader.ReadSlice('\n')
+  offset := pos - 1 + int64(len(skip))
+  for err == bufio.ErrBufferFull {
+    skip, err = reader.ReadSlice('\n')
+    offset += int64(len(skip))
+  }
+  if err != nil && err != io.EOF {
+    return 0, err
+  }
+  return offset, nil
+}
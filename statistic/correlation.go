@@ -0,0 +1,46 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statistic
+
+import (
+  "math"
+  "github.com/haskelladdict/lizard/autocorr"
+)
+
+
+
+// correlation computes the autocorrelation function and the integrated
+// autocorrelation time of the time series data
+//
+// NOTE: If the noise of a short series drives the summed autocorrelation
+//       time to zero or below Tau and IndependentSize are set to NaN
+//       since they carry no meaning.
+func correlation(st *Stat, data []float64, opts Options) {
+
+  window := opts.Window
+  if window == 0.0 {
+    window = autocorr.DefaultWindow
+  }
+
+  acf := autocorr.Function(data)
+  tau, m, ok := autocorr.Time(acf, window)
+  if !(tau > 0.0) {
+    tau = math.NaN()
+  }
+  st.Tau = tau
+  st.IndependentSize = float64(st.Count)/tau
+  if ok {
+    st.TauWindow = m
+  }
+
+  lags := opts.MaxLag
+  if lags == 0 {
+    lags = m
+  }
+  if lags >= len(acf) {
+    lags = len(acf) - 1
+  }
+  st.ACF = append([]float64{}, acf[:lags+1]...)
+}
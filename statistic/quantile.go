@@ -0,0 +1,227 @@
+// Copyright 2014 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statistic
+
+import (
+  "fmt"
+  "math"
+  "github.com/haskelladdict/lizard/quickselect"
+)
+
+
+
+// DefaultQuantileMethod is the sample quantile definition used unless
+// requested otherwise (type 7 of Hyndman and Fan, the default of R)
+const DefaultQuantileMethod = 7
+
+
+
+// fuzz guards the integer part of n*p against rounding errors (as in R)
+const fuzz = 4*2.220446049250313e-16
+
+
+
+// continuous_params lists the parameters a and b of the continuous sample
+// quantile methods 4 - 9. The quantile is located at a + p*(n + 1 - a - b).
+var continuous_params = map[int][2]float64{
+  4: {0, 1},
+  5: {0.5, 0.5},
+  6: {0, 0},
+  7: {1, 1},
+  8: {1.0/3.0, 1.0/3.0},
+  9: {3.0/8.0, 3.0/8.0},
+}
+
+
+
+// quantile_pos describes the location of a sample quantile in the sorted
+// data as the lower order statistic j (starting at 0) and the weight of
+// the next order statistic
+type quantile_pos struct {
+  j int
+  gamma float64
+}
+
+
+
+// check_quantiles verifies that all probabilities are within [0, 1] and
+// the method is one of the nine sample quantile types
+func check_quantiles(probs []float64, method int) error {
+
+  if method < 1 || method > 9 {
+    return fmt.Errorf("unknown quantile method %d (valid: 1-9)", method)
+  }
+
+  for _, p := range probs {
+    if !(p >= 0.0 && p <= 1.0) {
+      return fmt.Errorf("quantile probability %v outside of [0, 1]", p)
+    }
+  }
+  return nil
+}
+
+
+
+// Quantiles computes the sample quantiles of data for probabilities probs
+// using one of the nine definitions of Hyndman and Fan (1996) numbered as
+// in R's quantile function (method 1 - 9). All required order statistics
+// are found in a single selection pass via quickselect.Multiselect.
+//
+// NOTE: This operation is O(n) but reorders data in place and requires
+//       to keep the complete dataset in memory.
+//
+// NOTE: If data contains NaN values or is empty all quantiles are NaN.
+func Quantiles(data []float64, probs []float64, method int) ([]float64,
+  error) {
+
+  if err := check_quantiles(probs, method); err != nil {
+    return nil, err
+  }
+
+  methods := make([]int, len(probs))
+  for i := range methods {
+    methods[i] = method
+  }
+  return quantiles(data, probs, methods), nil
+}
+
+
+
+// quantiles computes the sample quantile for each probability in probs
+// using the corresponding method in a single selection pass
+func quantiles(data []float64, probs []float64, methods []int) []float64 {
+
+  quants := make([]float64, len(probs))
+  if len(data) == 0 || has_nan(data) {
+    for i := range quants {
+      quants[i] = math.NaN()
+    }
+    return quants
+  }
+
+  // collect all required order statistics
+  n := len(data)
+  pos := make([]quantile_pos, len(probs))
+  ks := make([]int, 0, 2*len(probs))
+  for i, p := range probs {
+    pos[i] = quantile_position(n, p, methods[i])
+    ks = append(ks, clamp(pos[i].j, n), clamp(pos[i].j+1, n))
+  }
+
+  order := quickselect.Multiselect(data, ks)
+  for i, qp := range pos {
+    lo, hi := order[2*i], order[2*i+1]
+    if qp.gamma == 0.0 {
+      quants[i] = lo
+    } else {
+      quants[i] = lo + qp.gamma*(hi - lo)
+    }
+  }
+  return quants
+}
+
+
+
+// order_statistics computes the median, the requested quantiles and the
+// interquartile range of the accumulated data. Exact values are computed
+// in a single selection pass with the median always using the default
+// method. Otherwise the values are estimated from the sketch.
+func order_statistics(st *Stat, acc accumulator, opts Options) {
+
+  probs := append([]float64{0.5, 0.25, 0.75}, opts.Quantiles...)
+
+  var quants []float64
+  if acc.sketch != nil {
+    quants = acc.sketch.Quantiles(probs)
+    st.RankError = acc.sketch.RankError()
+  } else {
+    method := opts.quantile_method()
+    methods := []int{DefaultQuantileMethod, method, method}
+    for range opts.Quantiles {
+      methods = append(methods, method)
+    }
+    quants = quantiles(acc.data, probs, methods)
+  }
+
+  if opts.Median {
+    st.Median = quants[0]
+  }
+  if len(opts.Quantiles) != 0 {
+    st.IQR = quants[2] - quants[1]
+    st.Quantiles = quants[3:]
+  }
+}
+
+
+
+// quantile_position determines the order statistic and interpolation
+// weight of the sample quantile for probability p of n data points
+func quantile_position(n int, p float64, method int) quantile_pos {
+
+  nf := float64(n)
+  var nppm float64
+  switch method {
+  case 1, 2:
+    nppm = nf*p
+  case 3:
+    nppm = nf*p - 0.5
+  default:
+    ab := continuous_params[method]
+    nppm = ab[0] + p*(nf + 1 - ab[0] - ab[1])
+  }
+
+  j := math.Floor(nppm + fuzz)
+  g := nppm - j
+  if math.Abs(g) < fuzz {
+    g = 0.0
+  }
+
+  gamma := g
+  switch method {
+  case 1:
+    gamma = step(g > 0.0)
+  case 2:
+    gamma = 0.5*(1.0 + step(g > 0.0))
+  case 3:
+    gamma = step(g > 0.0 || int(j)%2 == 1)
+  }
+
+  // j is the 1 based index of the lower order statistic
+  return quantile_pos{int(j)-1, gamma}
+}
+
+
+
+// step converts a bool into 0.0 or 1.0
+func step(b bool) float64 {
+  if b {
+    return 1.0
+  }
+  return 0.0
+}
+
+
+
+// clamp restricts an index to [0, n)
+func clamp(k, n int) int {
+  if k < 0 {
+    return 0
+  } else if k >= n {
+    return n-1
+  }
+  return k
+}
+
+
+
+// has_nan returns true if data contains at least one NaN
+func has_nan(data []float64) bool {
+  for _, v := range data {
+    if math.IsNaN(v) {
+      return true
+    }
+  }
+  return false
+}
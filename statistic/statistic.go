@@ -5,15 +5,21 @@
 // Package statistic provides functions for computing standard
 // statistic properties (mean, std, ...) for a slice of floats
 //
+// The statistic of a single input stream is computed via FromReader,
+// Statistic processes a list of files concurrently.
+//
 package statistic
 
 import (
-  "bufio"
+  "errors"
+  "fmt"
+  "io"
   "log"
-  "os"
-  "strconv"
-  "strings"
+  "math"
+  "github.com/haskelladdict/lizard/parser"
+  "github.com/haskelladdict/lizard/pool"
   "github.com/haskelladdict/lizard/quickselect"
+  "github.com/haskelladdict/lizard/sketch"
 )
 
 
@@ -23,205 +29,481 @@ type column []float64
 
 
 
-// stat describes a struct containing the computed statistics
-type stat struct {
+// ErrInvalidWeight is reported for negative or NaN weights
+var ErrInvalidWeight = errors.New("invalid weight")
+
+
+
+// Options describes the columns to process and the quantities to compute
+type Options struct {
+  Columns []parser.Column
+  Median bool             // expensive - don't do by default
+  Quantiles []float64     // probabilities of the requested quantiles
+  QuantileMethod int      // sample quantile type 1 - 9, 0 for the default
+  Approximate bool        // estimate median and quantiles in bounded
+                          // memory via a streaming sketch
+  SketchK int             // accuracy of the sketch, 0 for the default
+  Weight *parser.Column   // column of non-negative per-row weights, nil
+                          // for none. Median and quantiles are unweighted.
+  Correlation bool        // treat each column as a time series and
+                          // compute its autocorrelation - requires to
+                          // keep the column in memory like the median
+  MaxLag int              // largest lag of the reported autocorrelation
+                          // function, 0 for the summation window of Tau
+  Window float64          // factor of the automatic window of Tau, 0
+                          // for the default
+  Parse parser.Config
+}
+
+
+
+// Stat describes a struct containing the computed statistics of a single
+// column of a file
+type Stat struct {
   Name string
+  Column string
   Mean float64
   Variance float64
   Median float64
+  Skipped int         // number of missing values skipped
+  Count int           // number of samples
+  Min float64
+  Max float64
+  StdErr float64      // standard error of the mean
+  Skewness float64
+  Kurtosis float64    // excess kurtosis
+  Quantiles []float64 // requested quantiles in the order of Options
+  IQR float64         // interquartile range, only if quantiles requested
+  RankError float64   // normalized rank error of approximate median and
+                      // quantiles, 0 if exact
+  EffectiveSize float64  // effective sample size (sum w)^2/sum w^2, equal
+                         // to Count without weights
+  Tau float64            // integrated autocorrelation time (unweighted),
+                         // NaN if it can not be estimated
+  TauWindow int          // summation window of Tau, 0 if the automatic
+                         // window was not reached
+  IndependentSize float64  // number of independent samples Count/Tau,
+                           // NaN if Tau is
+  ACF []float64          // normalised autocorrelation function for lags
+                         // 0 to MaxLag
 }
 
 
 
-// job described the parsing work to be done by a single worker
-type job struct {
-  fileName string
-  colID int
-  wantMedian bool     // expensive - don't do by default
-  results chan<- stat
+// accumulator keeps track of the running statistic of a single column.
+// m2, m3 and m4 are the (weighted) sums of the second, third and fourth
+// powers of the deviations from the mean. Without weights each sample
+// has weight 1.
+type accumulator struct {
+  count int
+  weight, weight2 float64  // sum of the weights and of their squares
+  mean, m2, m3, m4 float64
+  min, max float64
+  keep_data bool
+  data []float64      // only used for exact order statistics and the
+                      // autocorrelation
+  sketch *sketch.KLL  // only used for approximate order statistics
 }
 
 
 
-// done is used to signal that a worker has finished his assigned
-// jobs. The done struct also conveys how many files were successfully
-// processed so the analysis routine can do a proper average
-type doneStatus struct {
-  files_processed int
+// partial contains the accumulated statistics of all requested columns
+// of a file or of a chunk of a file, the number of lines read and the
+// error encountered, if any
+type partial struct {
+  accs []accumulator
+  skipped []int       // number of missing values skipped per column
+  lines int
+  err error
 }
 
 
 
-// add_jobs adds all parsing jobs to the work queue (one per data file)
-func add_jobs(fileNames []string, colID int, wantMedian bool, jobs chan<- job,
-  result chan<- stat) {
-  for _, name := range fileNames {
-    jobs <- job{name, colID, wantMedian, result}
+// FromReader computes the mean, variance, median and quantiles (if
+// requested) of the requested columns of a plain text column oriented
+// data stream in a single pass. Comment, blank and header lines are
+// skipped and missing values handled according to opts.Parse. The name of
+// the input is used for error reporting and the Name of the returned
+// statistics. Parse failures are returned as *parser.ParseError.
+func FromReader(r io.Reader, name string, opts Options) ([]Stat, error) {
+
+  if err := opts.validate(); err != nil {
+    return nil, err
   }
-  close(jobs)
+
+  scanner := parser.NewScanner(r, name, opts.Parse)
+  p := scan(scanner, nil, opts)
+  if p.err != nil {
+    return nil, p.err
+  }
+  return p.stats(name, opts), nil
 }
 
 
 
-// start_jobs starts jobs still in the queue one by one. Each
-// worker processes a separate start_jobs goroutine
-func start_jobs(done chan<- doneStatus, jobs <-chan job) {
-  num_processed := 0
-  for job := range jobs {
-    success := job.run()
-    if success {
-      num_processed++
-    }
+// ReadColumns parses the requested columns of a plain text column
+// oriented data stream into memory for analyses which require the
+// complete data, e.g., histograms. Lines and missing values are handled
+// as by FromReader. The weight column, if any, is validated but not
+// returned.
+func ReadColumns(r io.Reader, name string, opts Options) ([][]float64,
+  error) {
+
+  // NOTE: exact order statistics keep the complete column around
+  opts.Median, opts.Quantiles, opts.Approximate = true, nil, false
+
+  scanner := parser.NewScanner(r, name, opts.Parse)
+  p := scan(scanner, nil, opts)
+  if p.err != nil {
+    return nil, p.err
+  }
+
+  cols := make([][]float64, len(p.accs))
+  for i, acc := range p.accs {
+    cols[i] = acc.data
   }
-  done <- doneStatus{num_processed}
+  return cols, nil
 }
 
 
 
-// run_jobs does the actual processing of a single job descriptor,
-// i.e., it parses the file and computes the statistic 
-//
-// NOTE: The computation of the mean and variance uses Welford's method
-//       so we can do away with a single pass through the data.
-//       see: Donald Knuth's AOCP, Vol 2, page 232, 3rd edition
-//
-// NOTE: the column parsing code below can panic in case the user supplies
-//       an invalid column ID or the data file itself is damaged. In this
-//       case we recover and ignore the whole file.
-func (j job) run() bool {
-
-  // ignore data file processed by this job in case of a panic
-  defer func() bool {
-    if r := recover(); r != nil {
-      log.Printf("Warning: Failed to parse file %s. Ignoring file. " +
-        "Did you pick the correct column??", j.fileName)
+// scan accumulates the requested columns of all data lines of scanner.
+// If colIDs is nil the columns are resolved once the first data line
+// was read.
+func scan(scanner *parser.Scanner, colIDs []int, opts Options) partial {
+
+  cols, conf := opts.columns(), opts.Parse
+  p := new_partial(opts)
+  vals := make([]float64, len(cols))
+
+  for scanner.Scan() {
+    if colIDs == nil {
+      if colIDs, p.err = scanner.Columns(cols); p.err != nil {
+        return p
+      }
     }
-    return false
-  }()
-
-  // main processing 
-  // NOTE: If filename is empty we assume stdin
-  var file *os.File
-  if j.fileName == "" {
-    file = os.Stdin
-  } else {
-    var err error
-    file, err = os.Open(j.fileName)
-    if err != nil {
-      log.Printf("Warning: Failed to open file %s. Ignoring file.\n",
-        j.fileName)
-      return false
+
+    skip := false
+    for i, colID := range colIDs {
+      val, missing, err := scanner.Value(colID)
+      if err != nil {
+        p.err = err
+        return p
+      }
+      if missing && conf.Missing == parser.MissingSkip {
+        if i < len(p.skipped) {
+          p.skipped[i]++
+        }
+        skip = true
+      }
+      vals[i] = val
+    }
+
+    // rows with missing values are skipped for all columns
+    if skip {
+      continue
     }
+
+    if opts.Weight == nil {
+      for i := range p.accs {
+        p.accs[i].push(vals[i])
+      }
+      continue
+    }
+
+    w := vals[len(vals)-1]
+    if !(w >= 0.0) {
+      p.err = scanner.FieldError(colIDs[len(colIDs)-1], ErrInvalidWeight)
+      return p
+    }
+    for i := range p.accs {
+      p.accs[i].push_weighted(vals[i], w)
+    }
+  }
+  p.err = scanner.Err()
+  p.lines = scanner.Line()
+  return p
+}
+
+
+
+// validate checks the quantile and autocorrelation settings of the
+// options
+func (o Options) validate() error {
+
+  if o.MaxLag < 0 {
+    return fmt.Errorf("invalid maximum lag %d", o.MaxLag)
+  } else if !(o.Window >= 0.0) {
+    return fmt.Errorf("invalid autocorrelation window %v", o.Window)
   }
-  defer file.Close()
+  return check_quantiles(o.Quantiles, o.quantile_method())
+}
+
+
 
-  mean, variance, median, err := compute_statistic(file, j.colID, j.wantMedian)
-  if err != nil {
-    log.Printf("Warning: Failed to parse file %s. Ignoring.\n", j.fileName)
-    return false
+// quantile_method returns the requested sample quantile method
+func (o Options) quantile_method() int {
+  if o.QuantileMethod == 0 {
+    return DefaultQuantileMethod
   }
+  return o.QuantileMethod
+}
+
 
-  j.results <- stat{j.fileName, mean, variance, median}
-  return true
+
+// columns returns the columns to be read, i.e., the requested columns
+// followed by the weight column, if any
+func (o Options) columns() []parser.Column {
+  if o.Weight == nil {
+    return o.Columns
+  }
+  return append(append([]parser.Column{}, o.Columns...), *o.Weight)
 }
 
 
 
-// compute_statistic computes the mean, variance and median (id requested)
-// of column colID of a plain text column oriented data file
-func compute_statistic(file *os.File, colID int, wantMedian bool) (float64, float64, float64, error) {
+// order_statistics returns true if median or quantiles are requested
+func (o Options) order_statistics() bool {
+  return o.Median || len(o.Quantiles) != 0
+}
+
+
+
+// new_accumulator returns an empty accumulator prepared for the order
+// statistics requested in opts. Exact order statistics and the
+// autocorrelation require the complete column to be kept in memory
+// whereas approximate order statistics use a sketch of bounded size.
+func new_accumulator(opts Options) accumulator {
 
-  var count int
-  var m_old, s_old, m, s float64
-  var data []float64
-  if wantMedian {
-    data = make([]float64, 0)
+  var acc accumulator
+  acc.keep_data = opts.Correlation
+  if !opts.order_statistics() {
+    return acc
   }
 
-  scanner := bufio.NewScanner(file)
-  for scanner.Scan() {
-    elems := strings.TrimSpace(strings.Fields(scanner.Text())[colID])
-    col, err := strconv.ParseFloat(elems, 64)
-    if err != nil {
-      return 0.0, 0.0, 0.0, err
+  if opts.Approximate {
+    k := opts.SketchK
+    if k == 0 {
+      k = sketch.DefaultK
     }
+    acc.sketch = sketch.NewKLL(k)
+  } else {
+    acc.keep_data = true
+  }
+  return acc
+}
+
+
+
+// new_partial returns an empty partial statistic for the columns
+// requested in opts
+func new_partial(opts Options) partial {
+
+  p := partial{accs: make([]accumulator, len(opts.Columns)),
+    skipped: make([]int, len(opts.Columns))}
+  for i := range p.accs {
+    p.accs[i] = new_accumulator(opts)
+  }
+  return p
+}
+
 
-    if wantMedian {
-      data = append(data, col)
-    }
 
-    count++
-    if count == 1 {
-      m_old = col
-      m = col
-    } else {
-      m = m_old + (col - m_old)/float64(count)
-      s = s_old + (col - m_old)*(col - m)
-      m_old = m
-      s_old = s
+// merge adds the partial statistic o of the data following p to p
+func (p *partial) merge(o partial) {
+  for i := range p.accs {
+    p.accs[i].merge(o.accs[i])
+    p.skipped[i] += o.skipped[i]
+  }
+  p.lines += o.lines
+}
+
+
+
+// stats turns the partial statistic into the final statistic of each
+// column
+func (p partial) stats(name string, opts Options) []Stat {
+
+  stats := make([]Stat, len(opts.Columns))
+  for i, acc := range p.accs {
+    stats[i] = acc.stat()
+    stats[i].Name = parser.Label(name)
+    stats[i].Column = opts.Columns[i].String()
+    stats[i].Skipped = p.skipped[i]
+
+    // NOTE: the exact order statistics reorder the data
+    if opts.Correlation {
+      correlation(&stats[i], acc.data, opts)
+    }
+    if opts.order_statistics() {
+      order_statistics(&stats[i], acc, opts)
     }
   }
+  return stats
+}
+
+
+
+// push adds a value to the running statistic using Welford's method
+// extended to the third and fourth central moments
+//
+// NOTE: see T. B. Terriberry, Computing Higher-Order Moments Online
+//       (2007) and P. Pebay, SAND2008-6212 (2008)
+func (a *accumulator) push(val float64) {
 
-  var median float64
-  if wantMedian {
-    median = Median(data)
+  if a.keep_data {
+    a.data = append(a.data, val)
   }
+  if a.sketch != nil {
+    a.sketch.Update(val)
+  }
+
+  a.count++
+  a.weight++
+  a.weight2++
+  if a.count == 1 {
+    a.mean = val
+    a.min = val
+    a.max = val
+    return
+  }
+
+  a.min = math.Min(a.min, val)
+  a.max = math.Max(a.max, val)
 
-  return m, s/float64(count-1), median, nil
+  n := float64(a.count)
+  delta := val - a.mean
+  delta_n := delta/n
+  delta_n2 := delta_n*delta_n
+  term := delta*delta_n*(n-1)
+
+  a.mean += delta_n
+  a.m4 += term*delta_n2*(n*n - 3*n + 3) + 6*delta_n2*a.m2 - 4*delta_n*a.m3
+  a.m3 += term*delta_n*(n-2) - 3*delta_n*a.m2
+  a.m2 += term
 }
 
 
 
-// process_column adds a column to the provided accumulator column
-func process_column(result column, acc column) column {
+// push_weighted adds a value with weight w to the running statistic. The
+// value is combined with the accumulated ones as a sample of weight w
+// via the pairwise update of the moments.
+func (a *accumulator) push_weighted(val, w float64) {
 
-  if len(acc) != 0 {
+  if a.keep_data {
+    a.data = append(a.data, val)
+  }
+  if a.sketch != nil {
+    a.sketch.Update(val)
+  }
 
-    if len(result) != len(acc) {
-      log.Panic("Mismatched column length in data files. Bailing out...")
-    }
+  a.merge_moments(accumulator{count: 1, weight: w, weight2: w*w,
+    mean: val, min: val, max: val})
+}
 
-    for i, v := range result {
-      acc[i] += v
-    }
-  } else {
-    acc = result
+
+
+// merge combines the accumulator o with a
+func (a *accumulator) merge(o accumulator) {
+
+  if a.keep_data {
+    a.data = append(a.data, o.data...)
+  }
+  if a.sketch != nil {
+    a.sketch.Merge(o.sketch)
   }
+  a.merge_moments(o)
+}
 
-  return acc
+
+
+// merge_moments combines the moments of accumulator o with the ones of a
+// using the pairwise update of the central moments of weighted samples
+//
+// NOTE: see T. F. Chan, G. H. Golub and R. J. LeVeque, Updating Formulae
+//       and a Pairwise Algorithm for Computing Sample Variances (1979)
+//       and P. Pebay, SAND2008-6212 (2008) for the higher moments
+func (a *accumulator) merge_moments(o accumulator) {
+
+  if o.count == 0 {
+    return
+  } else if a.count == 0 {
+    a.count, a.mean, a.min, a.max = o.count, o.mean, o.min, o.max
+    a.weight, a.weight2 = o.weight, o.weight2
+    a.m2, a.m3, a.m4 = o.m2, o.m3, o.m4
+    return
+  }
+
+  a.count += o.count
+  a.min = math.Min(a.min, o.min)
+  a.max = math.Max(a.max, o.max)
+
+  na, nb := a.weight, o.weight
+  a.weight += o.weight
+  a.weight2 += o.weight2
+  if nb == 0.0 {
+    return
+  } else if na == 0.0 {
+    a.mean, a.m2, a.m3, a.m4 = o.mean, o.m2, o.m3, o.m4
+    return
+  }
+
+  n := na + nb
+  delta := o.mean - a.mean
+  delta_n := delta/n
+  delta_n2 := delta_n*delta_n
+  term := delta*delta_n*na*nb
+
+  m4 := a.m4 + o.m4 + term*delta_n2*(na*na - na*nb + nb*nb) +
+    6*delta_n2*(na*na*o.m2 + nb*nb*a.m2) + 4*delta_n*(na*o.m3 - nb*a.m3)
+  m3 := a.m3 + o.m3 + term*delta_n*(na - nb) + 3*delta_n*(na*o.m2 - nb*a.m2)
+  a.m2 += o.m2 + term
+  a.m3, a.m4 = m3, m4
+  a.mean += delta_n*nb
 }
 
 
 
-// wait_and_process_results starts with data processing (averaging) while 
-// waiting for all workers to finish 
-func wait_and_process_results(results <-chan stat, done <-chan doneStatus,
-  num_workers int) []stat {
+// stat turns the accumulated moments into the corresponding statistic.
+// Skewness and excess kurtosis are the moment coefficients g1 and g2
+// without small sample corrections. For weighted samples the variance is
+// the unbiased estimate for reliability weights and the standard error is
+// based on the effective sample size.
+func (a *accumulator) stat() Stat {
 
-  output := make([]stat, 0)
+  w := a.weight
+  n_eff := w*w/a.weight2
+  variance := a.m2/(w - a.weight2/w)
+  st := Stat{Count: a.count, Mean: a.mean, Variance: variance,
+    StdErr: math.Sqrt(variance/n_eff), Min: a.min, Max: a.max,
+    Skewness: math.Sqrt(w)*a.m3/math.Pow(a.m2, 1.5),
+    Kurtosis: w*a.m4/(a.m2*a.m2) - 3.0, EffectiveSize: n_eff}
 
-  for w := 0; w < num_workers; {
-    select {  // Blocking
-    case result := <-results:
-      output = append(output, result)
-    case <-done:
-      num_workers--
-    }
+  if a.count == 0 {
+    st.Mean, st.Min, st.Max = math.NaN(), math.NaN(), math.NaN()
   }
+  return st
+}
 
-DONE:
 
-  // process any remaining results
-  for {
-    select {
-    case result := <-results:
-      output = append(output, result)
-    default:
-      break DONE
+
+// process_column adds a column to the provided accumulator column
+func process_column(result column, acc column) column {
+
+  if len(acc) != 0 {
+
+    if len(result) != len(acc) {
+      log.Panic("Mismatched column length in data files. Bailing out...")
+    }
+
+    for i, v := range result {
+      acc[i] += v
     }
+  } else {
+    acc = result
   }
 
-  return output
+  return acc
 }
 
 
@@ -232,8 +514,20 @@ DONE:
 // NOTE: This operation is only O(n) but requires to keep the 
 //       complete dataset in memory which may be prohibitive
 //       for large datasets.
+//
+// NOTE: If data contains NaN values or is empty the median is NaN.
 func Median(data []float64) float64 {
 
+  if len(data) == 0 {
+    return math.NaN()
+  }
+
+  for _, v := range data {
+    if math.IsNaN(v) {
+      return math.NaN()
+    }
+  }
+
   n := len(data)
   if  (n % 2) == 0 {
     return (quickselect.Quickselect(data, n/2-1) + quickselect.Quickselect(data, n/2))/2.0
@@ -249,21 +543,49 @@ func Median(data []float64) float64 {
 //
 // NOTE: If the list of fileNames is empty we assume input from stdin
 //
-// NOTE: The computation of the median is segregated out since it in
-//       contrast to the mean/std it requires us to store the complete 
-//       content of the data file in memory which may be prohibitive
-func Statistic(fileNames []string, colID int, wantMedian bool, numWorkers int) []stat {
-
-  jobs := make(chan job, numWorkers)
-  result := make(chan stat, len(fileNames))
-  done := make(chan doneStatus, numWorkers)
-
-  go add_jobs(fileNames, colID, wantMedian, jobs, result)
-  for i := 0; i < numWorkers; i++ {
-    go start_jobs(done, jobs)
+// NOTE: Comment, blank and header lines are handled according to
+//       opts.Parse.
+//       Columns selected by name are looked up in the last header line.
+//       All columns are computed in a single pass through each file and
+//       there is one result per file and column. Results are ordered
+//       by file as in fileNames and then by column.
+//
+// NOTE: If there are fewer files than workers the remaining workers are
+//       used to process large files in chunks (see FromFile).
+//
+// NOTE: Files which can not be opened or parsed are ignored. Their errors
+//       are returned as parser.Errors alongside the results of all other
+//       files.
+//
+// NOTE: The computation of the median and quantiles is segregated out
+//       since it in contrast to the mean/std it requires us to store the
+//       complete content of the data file in memory which may be
+//       prohibitive. With opts.Approximate the median and quantiles are
+//       estimated in bounded memory instead.
+func Statistic(fileNames []string, opts Options,
+  numWorkers int) ([]Stat, error) {
+
+  if err := opts.validate(); err != nil {
+    return nil, err
   }
 
-  return wait_and_process_results(result, done, numWorkers)
+  // surplus workers process large files in chunks
+  chunks := 1
+  if len(fileNames) != 0 && len(fileNames) < numWorkers {
+    chunks = numWorkers/len(fileNames)
+    numWorkers = len(fileNames)
+  }
+
+  stats, err := pool.Collect(fileNames, numWorkers,
+    func(name string) ([]Stat, error) {
+      return FromFile(name, opts, chunks)
+    })
+
+  output := make([]Stat, 0)
+  for _, st := range stats {
+    output = append(output, st...)
+  }
+  return output, err
 }
 
 
@@ -10,8 +10,11 @@
 package statistic
 
 import (
+  "errors"
   "math"
+  "strings"
   "testing"
+  "github.com/haskelladdict/lizard/parser"
 )
 
 
@@ -19,38 +22,527 @@ import (
 func Test_Average_1(t *testing.T) {
 
   data_file_1 := "test_files/test_data_1.txt"
-  result_1 := Statistic([]string{data_file_1}, 0, true, 4)
-  s1_1 := stat{data_file_1, 5.5, 9.166666666666666, 5.5}
-  expected_1 := []stat{s1_1}
+  result_1, _ := Statistic([]string{data_file_1},
+    Options{Columns: []parser.Column{{Index: 0}}, Median: true,
+      Parse: parser.DefaultConfig()}, 4)
+  s1_1 := Stat{Name: data_file_1, Column: "0", Mean: 5.5,
+    Variance: 9.166666666666666, Median: 5.5}
+  expected_1 := []Stat{s1_1}
   if !stat_equal(result_1, expected_1) {
     t.Error("Statistic test 1 failed")
   }
 
   data_file_2 := "test_files/test_data_2.txt"
-  result_2 := Statistic([]string{data_file_2}, 0, true, 4)
-  s1_2 := stat{data_file_2, 0.41319134487140002, 0.082911176230414732,
-               0.337045349500000}
-  expected_2 := []stat{s1_2}
+  result_2, _ := Statistic([]string{data_file_2},
+    Options{Columns: []parser.Column{{Index: 0}}, Median: true,
+      Parse: parser.DefaultConfig()}, 4)
+  s1_2 := Stat{Name: data_file_2, Column: "0", Mean: 0.41319134487140002,
+    Variance: 0.082911176230414732, Median: 0.337045349500000}
+  expected_2 := []Stat{s1_2}
   if !stat_equal(result_2, expected_2) {
     t.Error("Statistic test 2 failed")
   }
 
   data_file_3 := "test_files/test_data_3.txt"
-  result_3 := Statistic([]string{data_file_3}, 1, true, 4)
-  s1_3 := stat{data_file_3, 0.49905688017419975, 0.083507191091550331,
-               0.498817626000000}
-  expected_3 := []stat{s1_3}
+  result_3, _ := Statistic([]string{data_file_3},
+    Options{Columns: []parser.Column{{Index: 1}}, Median: true,
+      Parse: parser.DefaultConfig()}, 4)
+  s1_3 := Stat{Name: data_file_3, Column: "1", Mean: 0.49905688017419975,
+    Variance: 0.083507191091550331, Median: 0.498817626000000}
+  expected_3 := []Stat{s1_3}
   if !stat_equal(result_3, expected_3) {
     t.Error("Statistic test 3 failed")
   }
 }
 
 
+// Tests for comment, blank and header line handling
+func Test_Average_2(t *testing.T) {
+
+  data_file_4 := "test_files/test_data_4.txt"
+  conf := parser.DefaultConfig()
+  conf.HeaderLines = 1
+  result_4, _ := Statistic([]string{data_file_4},
+    Options{Columns: []parser.Column{{Index: 1}}, Median: true, Parse: conf}, 4)
+  s1_4 := Stat{Name: data_file_4, Column: "1", Mean: 5.5,
+    Variance: 9.166666666666666, Median: 5.5}
+  expected_4 := []Stat{s1_4}
+  if !stat_equal(result_4, expected_4) {
+    t.Error("Statistic test 4 failed")
+  }
+
+  cols_5 := []parser.Column{{Name: "value"}}
+  result_5, _ := Statistic([]string{data_file_4},
+    Options{Columns: cols_5, Median: true, Parse: conf}, 4)
+  s1_5 := Stat{Name: data_file_4, Column: "value", Mean: 5.5,
+    Variance: 9.166666666666666, Median: 5.5}
+  expected_5 := []Stat{s1_5}
+  if !stat_equal(result_5, expected_5) {
+    t.Error("Statistic test 5 failed")
+  }
+
+  cols_6 := []parser.Column{{Name: "energy"}}
+  result_6, err := Statistic([]string{data_file_4},
+    Options{Columns: cols_6, Median: true, Parse: conf}, 4)
+  if len(result_6) != 0 || err == nil {
+    t.Error("Statistic test 6 failed - unknown column name was accepted")
+  }
+}
+
+
+// Tests for computing the statistic of several columns at once
+func Test_Average_3(t *testing.T) {
+
+  data_file_4 := "test_files/test_data_4.txt"
+  conf := parser.DefaultConfig()
+  conf.HeaderLines = 1
+  cols := []parser.Column{{Name: "time"}, {Index: 1}}
+  result_7, _ := Statistic([]string{data_file_4},
+    Options{Columns: cols, Median: true, Parse: conf}, 4)
+  s1_7 := Stat{Name: data_file_4, Column: "time", Mean: 5.5,
+    Variance: 9.166666666666666, Median: 5.5}
+  s2_7 := Stat{Name: data_file_4, Column: "1", Mean: 5.5,
+    Variance: 9.166666666666666, Median: 5.5}
+  if !stat_equal(result_7, []Stat{s1_7, s2_7}) {
+    t.Error("Statistic test 7 failed")
+  }
+}
+
+
+// Tests for reading delimited files
+func Test_Average_4(t *testing.T) {
+
+  data_file_5 := "test_files/test_data_5.csv"
+  conf := parser.DefaultConfig()
+  conf.HeaderLines = 1
+  conf.Delimiter = ","
+  cols := []parser.Column{{Name: "value, measured"}}
+  result_8, _ := Statistic([]string{data_file_5},
+    Options{Columns: cols, Median: true, Parse: conf}, 4)
+  s1_8 := Stat{Name: data_file_5, Column: "value, measured", Mean: 5.5,
+    Variance: 9.166666666666666, Median: 5.5}
+  if !stat_equal(result_8, []Stat{s1_8}) {
+    t.Error("Statistic test 8 failed")
+  }
+}
+
+
+// Tests for the missing value policies
+func Test_Average_5(t *testing.T) {
+
+  data_file_6 := "test_files/test_data_6.txt"
+  cols := []parser.Column{{Index: 0}, {Index: 1}}
+  conf := parser.DefaultConfig()
+
+  result_9, err := Statistic([]string{data_file_6},
+    Options{Columns: cols, Median: false, Parse: conf}, 4)
+  if len(result_9) != 0 {
+    t.Error("Statistic test 9 failed - missing values were accepted")
+  }
+
+  var parseErr *parser.ParseError
+  if !errors.As(err, &parseErr) || parseErr.File != data_file_6 ||
+     parseErr.Line != 2 || parseErr.Column != 1 || parseErr.Token != "NA" {
+    t.Errorf("Statistic test 9 failed - unexpected error %v", err)
+  }
+
+  conf.Missing = parser.MissingSkip
+  result_10, _ := Statistic([]string{data_file_6},
+    Options{Columns: cols, Median: false, Parse: conf}, 4)
+  s1_10 := Stat{Name: data_file_6, Column: "0", Mean: 3.25,
+    Variance: 2.9166666666666665, Median: 0.0, Skipped: 1}
+  s2_10 := Stat{Name: data_file_6, Column: "1", Mean: 3.25,
+    Variance: 2.9166666666666665, Median: 0.0, Skipped: 1}
+  if !stat_equal(result_10, []Stat{s1_10, s2_10}) {
+    t.Error("Statistic test 10 failed")
+  }
+
+  conf.Missing = parser.MissingFill
+  conf.Fill = 3.0
+  result_11, _ := Statistic([]string{data_file_6},
+    Options{Columns: cols, Median: false, Parse: conf}, 4)
+  if len(result_11) != 2 || !float_equal(result_11[0].Mean, 3.0) ||
+     !float_equal(result_11[0].Variance, 2.0) {
+    t.Error("Statistic test 11 failed")
+  }
+
+  conf.Missing = parser.MissingNaN
+  result_12, _ := Statistic([]string{data_file_6},
+    Options{Columns: cols, Median: true, Parse: conf}, 4)
+  if len(result_12) != 2 || !math.IsNaN(result_12[0].Mean) ||
+     !math.IsNaN(result_12[1].Median) {
+    t.Error("Statistic test 12 failed")
+  }
+}
+
+
+// Tests for reporting errors of files which fail to parse
+func Test_Average_6(t *testing.T) {
+
+  data_file_1 := "test_files/test_data_1.txt"
+  data_file_6 := "test_files/test_data_6.txt"
+  cols := []parser.Column{{Index: 1}}
+  result_13, err := Statistic([]string{data_file_1, data_file_6,
+    "test_files/does_not_exist.txt"},
+    Options{Columns: cols, Median: false, Parse: parser.DefaultConfig()}, 4)
+  if len(result_13) != 0 {
+    t.Error("Statistic test 13 failed - expected no results")
+  }
+
+  errs, ok := err.(parser.Errors)
+  if !ok || len(errs) != 3 {
+    t.Fatalf("Statistic test 13 failed - unexpected error %v", err)
+  }
+
+  var parseErr *parser.ParseError
+  for _, e := range errs {
+    if errors.As(e, &parseErr) && parseErr.File == data_file_1 {
+      if parseErr.Line != 1 || !errors.Is(e, parser.ErrMissingField) {
+        t.Errorf("Statistic test 13 failed - unexpected error %v", e)
+      }
+      return
+    }
+  }
+  t.Error("Statistic test 13 failed - missing error for too few fields")
+}
+
+
+// Tests for computing the statistic of an in-memory stream
+func Test_Average_7(t *testing.T) {
+
+  input := strings.NewReader("# comment\nx y\n1 4\n2 5\n3 6\n")
+  conf := parser.DefaultConfig()
+  conf.HeaderLines = 1
+  opts := Options{Columns: []parser.Column{{Name: "y"}}, Median: true,
+    Parse: conf}
+  result_14, err := FromReader(input, "buffer", opts)
+  s1_14 := Stat{Name: "buffer", Column: "y", Mean: 5.0, Variance: 1.0,
+    Median: 5.0}
+  if err != nil || !stat_equal(result_14, []Stat{s1_14}) {
+    t.Error("Statistic test 14 failed")
+  }
+}
+
+
+// Tests for the order of results with multiple workers
+func Test_Average_8(t *testing.T) {
+
+  names := []string{"test_files/test_data_4.txt", "test_files/test_data_1.txt",
+    "test_files/test_data_2.txt", "test_files/test_data_1.txt"}
+  opts := Options{Columns: []parser.Column{{Index: 0}},
+    Parse: parser.DefaultConfig()}
+  opts.Parse.HeaderLines = 1
+
+  for k := 0; k < 20; k++ {
+    result_15, err := Statistic(names, opts, 4)
+    if err != nil || len(result_15) != len(names) {
+      t.Fatalf("Statistic test 15 failed - unexpected error %v", err)
+    }
+    for i, st := range result_15 {
+      if st.Name != names[i] {
+        t.Fatalf("Statistic test 15 failed - expected %v got %v at %v",
+          names[i], st.Name, i)
+      }
+    }
+  }
+}
+
+
+// Tests for higher order moments, extrema and sample counts
+func Test_Average_9(t *testing.T) {
+
+  input := strings.NewReader("2\n1\n10\n2\n3\n")
+  opts := Options{Columns: []parser.Column{{Index: 0}},
+    Parse: parser.DefaultConfig()}
+  result_16, err := FromReader(input, "buffer", opts)
+  if err != nil || len(result_16) != 1 {
+    t.Fatalf("Statistic test 16 failed - unexpected error %v", err)
+  }
+
+  st := result_16[0]
+  if st.Count != 5 || st.Min != 1.0 || st.Max != 10.0 {
+    t.Errorf("Statistic test 16 failed - wrong count or extrema %v", st)
+  }
+
+  if !float_equal(st.Mean, 3.6) || !float_equal(st.Variance, 13.3) ||
+     !float_equal(st.StdErr, 1.6309506430300091) ||
+     !float_equal(st.Skewness, 1.3608927294433226) ||
+     !float_equal(st.Kurtosis, 0.06803663293572226) {
+    t.Errorf("Statistic test 16 failed - wrong moments %v", st)
+  }
+}
+
+
+// Tests for the sample quantile definitions
+func Test_Quantiles_1(t *testing.T) {
+
+  tests := []struct {
+    method int
+    p float64
+    expected float64
+  }{
+    {1, 0.25, 1.0}, {1, 0.3, 2.0}, {2, 0.25, 1.5}, {3, 0.25, 1.0},
+    {3, 0.3125, 1.0}, {3, 0.4375, 3.0}, {4, 0.1, 1.0}, {5, 0.5, 3.5},
+    {6, 0.25, 1.25}, {7, 0.0, 1.0}, {7, 0.25, 1.75}, {7, 1.0, 9.0},
+    {8, 0.75, 5.0 + 7.0/12.0}, {9, 0.9, 8.4},
+  }
+
+  for _, test := range tests {
+    data := []float64{3, 1, 4, 1, 5, 9, 2, 6}
+    q, err := Quantiles(data, []float64{test.p}, test.method)
+    if err != nil || !float_equal(q[0], test.expected) {
+      t.Errorf("quantile test 1 failed - type %d p = %v: expected %v got %v",
+        test.method, test.p, test.expected, q)
+    }
+  }
+
+  if _, err := Quantiles([]float64{1}, []float64{1.5}, 7); err == nil {
+    t.Error("quantile test 1 failed - accepted probability outside [0, 1]")
+  }
+
+  if _, err := Quantiles([]float64{1}, []float64{0.5}, 10); err == nil {
+    t.Error("quantile test 1 failed - accepted unknown method")
+  }
+}
+
+
+// Tests for quantiles and interquartile range in file statistics
+func Test_Average_10(t *testing.T) {
+
+  data_file_1 := "test_files/test_data_1.txt"
+  opts := Options{Columns: []parser.Column{{Index: 0}}, Median: true,
+    Quantiles: []float64{0.1, 0.9}, Parse: parser.DefaultConfig()}
+  result_17, err := Statistic([]string{data_file_1}, opts, 4)
+  if err != nil || len(result_17) != 1 {
+    t.Fatalf("Statistic test 17 failed - unexpected error %v", err)
+  }
+
+  st := result_17[0]
+  if !float_equal(st.Median, 5.5) || len(st.Quantiles) != 2 ||
+     !float_equal(st.Quantiles[0], 1.9) || !float_equal(st.Quantiles[1], 9.1) ||
+     !float_equal(st.IQR, 4.5) {
+    t.Errorf("Statistic test 17 failed - unexpected quantiles %v", st)
+  }
+}
+
+
+// Tests for approximate quantiles in file statistics. For streams shorter
+// than the sketch size all items are kept and the estimates are exact
+// order statistics.
+func Test_Average_11(t *testing.T) {
+
+  data_file_1 := "test_files/test_data_1.txt"
+  opts := Options{Columns: []parser.Column{{Index: 0}}, Median: true,
+    Quantiles: []float64{0.1, 0.9}, Approximate: true,
+    Parse: parser.DefaultConfig()}
+  result_18, err := Statistic([]string{data_file_1}, opts, 4)
+  if err != nil || len(result_18) != 1 {
+    t.Fatalf("Statistic test 18 failed - unexpected error %v", err)
+  }
+
+  st := result_18[0]
+  if !float_equal(st.Median, 5.0) || len(st.Quantiles) != 2 ||
+     !float_equal(st.Quantiles[0], 1.0) || !float_equal(st.Quantiles[1], 9.0) ||
+     !float_equal(st.IQR, 5.0) || st.RankError <= 0.0 ||
+     !float_equal(st.Mean, 5.5) {
+    t.Errorf("Statistic test 18 failed - unexpected quantiles %v", st)
+  }
+}
+
+
+// Tests for processing a single file in concurrently processed chunks
+func Test_Average_12(t *testing.T) {
+
+  defer func(size int64) { min_chunk_size = size }(min_chunk_size)
+  min_chunk_size = 16
+
+  data_file_2 := "test_files/test_data_2.txt"
+  opts := Options{Columns: []parser.Column{{Index: 0}}, Median: true,
+    Parse: parser.DefaultConfig()}
+  expected_19, err := FromFile(data_file_2, opts, 1)
+  if err != nil {
+    t.Fatalf("Statistic test 19 failed - unexpected error %v", err)
+  }
+  result_19, err := FromFile(data_file_2, opts, 7)
+  if err != nil || !stat_equal(result_19, expected_19) {
+    t.Fatalf("Statistic test 19 failed - expected %v got %v (%v)",
+      expected_19, result_19, err)
+  }
+  for i := range result_19 {
+    r, e := result_19[i], expected_19[i]
+    if r.Count != e.Count || r.Min != e.Min || r.Max != e.Max ||
+       !float_equal(r.Skewness, e.Skewness) ||
+       !float_equal(r.Kurtosis, e.Kurtosis) {
+      t.Errorf("Statistic test 19 failed - expected %v got %v", e, r)
+    }
+  }
+
+  // header lines and comments interleaved with the chunks
+  data_file_4 := "test_files/test_data_4.txt"
+  conf := parser.DefaultConfig()
+  conf.HeaderLines = 1
+  opts = Options{Columns: []parser.Column{{Name: "value"}}, Parse: conf}
+  result_20, err := FromFile(data_file_4, opts, 4)
+  expected_20 := []Stat{{Name: data_file_4, Column: "value", Mean: 5.5,
+    Variance: 9.166666666666666}}
+  if err != nil || !stat_equal(result_20, expected_20) ||
+     result_20[0].Count != 10 {
+    t.Errorf("Statistic test 20 failed - expected %v got %v (%v)",
+      expected_20, result_20, err)
+  }
+
+  // line numbers of parse errors refer to the whole file
+  data_file_6 := "test_files/test_data_6.txt"
+  opts = Options{Columns: []parser.Column{{Index: 0}},
+    Parse: parser.DefaultConfig()}
+  _, err = FromFile(data_file_6, opts, 4)
+  var perr *parser.ParseError
+  if !errors.As(err, &perr) || perr.Line != 4 {
+    t.Errorf("Statistic test 21 failed - expected error in line 4 got %v",
+      err)
+  }
+}
+
+
+// Tests for the pairwise combination of accumulators
+func Test_Average_13(t *testing.T) {
+
+  data := []float64{2, 1, 10, 2, 3, -4, 0.5, 7}
+  var expected accumulator
+  for _, v := range data {
+    expected.push(v)
+  }
+
+  for split := 0; split <= len(data); split++ {
+    var a, b accumulator
+    for _, v := range data[:split] {
+      a.push(v)
+    }
+    for _, v := range data[split:] {
+      b.push(v)
+    }
+    a.merge(b)
+
+    r, e := a.stat(), expected.stat()
+    if r.Count != e.Count || r.Min != e.Min || r.Max != e.Max ||
+       !float_equal(r.Mean, e.Mean) || !float_equal(r.Variance, e.Variance) ||
+       !float_equal(r.Skewness, e.Skewness) ||
+       !float_equal(r.Kurtosis, e.Kurtosis) {
+      t.Errorf("Statistic test 22 failed - split %d: expected %v got %v",
+        split, e, r)
+    }
+  }
+}
+
+
+// Tests for weighted statistics
+func Test_Average_14(t *testing.T) {
+
+  input := strings.NewReader("1 1\n2 2\n3 1\n")
+  opts := Options{Columns: []parser.Column{{Index: 0}},
+    Weight: &parser.Column{Index: 1}, Parse: parser.DefaultConfig()}
+  result_23, err := FromReader(input, "buffer", opts)
+  if err != nil || len(result_23) != 1 {
+    t.Fatalf("Statistic test 23 failed - unexpected error %v", err)
+  }
+
+  st := result_23[0]
+  if st.Count != 3 || !float_equal(st.Mean, 2.0) ||
+     !float_equal(st.Variance, 0.8) ||
+     !float_equal(st.EffectiveSize, 8.0/3.0) ||
+     !float_equal(st.StdErr, math.Sqrt(0.3)) ||
+     !float_equal(st.Skewness+1.0, 1.0) {
+    t.Errorf("Statistic test 23 failed - wrong weighted moments %v", st)
+  }
+
+  // unit weights yield the unweighted statistic
+  input = strings.NewReader("2 1\n1 1\n10 1\n2 1\n3 1\n")
+  result_24, err := FromReader(input, "buffer", opts)
+  if err != nil || len(result_24) != 1 {
+    t.Fatalf("Statistic test 24 failed - unexpected error %v", err)
+  }
+
+  st = result_24[0]
+  if !float_equal(st.Mean, 3.6) || !float_equal(st.Variance, 13.3) ||
+     !float_equal(st.StdErr, 1.6309506430300091) ||
+     !float_equal(st.Skewness, 1.3608927294433226) ||
+     !float_equal(st.Kurtosis, 0.06803663293572226) ||
+     !float_equal(st.EffectiveSize, 5.0) {
+    t.Errorf("Statistic test 24 failed - wrong moments %v", st)
+  }
+
+  input = strings.NewReader("1 1\n2 -1\n")
+  _, err = FromReader(input, "buffer", opts)
+  var perr *parser.ParseError
+  if !errors.As(err, &perr) || perr.Line != 2 ||
+     !errors.Is(err, ErrInvalidWeight) {
+    t.Errorf("Statistic test 25 failed - expected weight error got %v", err)
+  }
+}
+
+
+// Tests for reading complete columns
+func Test_Average_15(t *testing.T) {
+
+  conf := parser.DefaultConfig()
+  conf.Missing = parser.MissingSkip
+  input := strings.NewReader("# a b\n1 4\n2 NA\n3 6\n")
+  opts := Options{Columns: []parser.Column{{Index: 1}, {Index: 0}},
+    Parse: conf}
+  result_26, err := ReadColumns(input, "buffer", opts)
+  if err != nil || len(result_26) != 2 ||
+     !float_array_equal(result_26[0], []float64{4, 6}) ||
+     !float_array_equal(result_26[1], []float64{1, 3}) {
+    t.Errorf("Statistic test 26 failed - got %v (error %v)", result_26, err)
+  }
+}
+
+
+// Tests for the autocorrelation of time series
+func Test_Average_16(t *testing.T) {
+
+  input := strings.NewReader("4\n3\n1\n2\n")
+  opts := Options{Columns: []parser.Column{{Index: 0}}, Median: true,
+    Correlation: true, Parse: parser.DefaultConfig()}
+  result_27, err := FromReader(input, "buffer", opts)
+  if err != nil || len(result_27) != 1 {
+    t.Fatalf("Statistic test 27 failed - unexpected error %v", err)
+  }
+
+  // the median must not reorder the time series
+  st := result_27[0]
+  if !float_equal(st.Median, 2.5) || st.TauWindow != 2 ||
+     !float_equal(st.Tau, 0.3) || !float_equal(st.IndependentSize, 4.0/0.3) ||
+     !float_array_equal(st.ACF, []float64{1.0, 0.15, -0.5}) {
+    t.Errorf("Statistic test 27 failed - got %v", st)
+  }
+
+  input = strings.NewReader("4\n3\n1\n2\n")
+  opts.MaxLag = 10
+  result_28, err := FromReader(input, "buffer", opts)
+  if err != nil || len(result_28) != 1 ||
+     !float_array_equal(result_28[0].ACF, []float64{1.0, 0.15, -0.5, -0.15}) {
+    t.Errorf("Statistic test 28 failed - got %v (error %v)", result_28, err)
+  }
+
+  // an alternating series drives tau below zero
+  input = strings.NewReader("1\n-1\n1\n-1\n")
+  result_29, err := FromReader(input, "buffer", opts)
+  if err != nil || len(result_29) != 1 || !math.IsNaN(result_29[0].Tau) ||
+     !math.IsNaN(result_29[0].IndependentSize) ||
+     result_29[0].TauWindow != 0 {
+    t.Errorf("Statistic test 29 failed - got %v (error %v)", result_29, err)
+  }
+}
+
+
 // Benchmarks
 func Benchmark_Average(t *testing.B) {
 
   data_file_3 := "test_files/test_data_3.txt"
-  Statistic([]string{data_file_3}, 1, true, 4)
+  Statistic([]string{data_file_3},
+    Options{Columns: []parser.Column{{Index: 1}}, Median: true,
+      Parse: parser.DefaultConfig()}, 4)
 }
 
 
@@ -58,7 +550,7 @@ func Benchmark_Average(t *testing.B) {
 //
 // stat_equal compares the entries of a slice of stat structures 
 // returned from a call to Average with a reference slice stat structure
-func stat_equal(s1, s2 []stat) bool {
+func stat_equal(s1, s2 []Stat) bool {
 
   if len(s1) != len(s2) {
     return false
@@ -70,6 +562,10 @@ func stat_equal(s1, s2 []stat) bool {
       status = false
     }
 
+    if s1[i].Column != s2[i].Column {
+      status = false
+    }
+
     if !float_equal(s1[i].Mean, s2[i].Mean) {
       status = false
     }
@@ -82,6 +578,10 @@ func stat_equal(s1, s2 []stat) bool {
       status = false
     }
 
+    if s1[i].Skipped != s2[i].Skipped {
+      status = false
+    }
+
   }
 
   return status
@@ -100,3 +600,20 @@ func float_equal(a1, a2 float64) bool {
   return true
 }
 
+
+
+
+// float_array_equal compares two arrays of floats for equality using
+// float_equal
+func float_array_equal(a1, a2 []float64) bool {
+  if len(a1) != len(a2) {
+    return false
+  }
+
+  for i, v := range a1 {
+    if !float_equal(v, a2[i]) {
+      return false
+    }
+  }
+  return true
+}